@@ -16,11 +16,11 @@ package cmd
 
 import (
 	"context"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -53,7 +53,7 @@ func handleSignals(listeners []context.CancelFunc) {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigs
-		color.Red("received signal", "signal", sig)
+		slog.Default().Warn("received signal", "signal", sig.String())
 		SignalReceived = true
 		for _, listener := range listeners {
 			listener()
@@ -18,17 +18,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/imerkle/rosetta-solana-go/configuration"
+	"github.com/imerkle/rosetta-solana-go/logger"
 	"github.com/imerkle/rosetta-solana-go/services"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
@@ -61,6 +64,8 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("%w: unable to load configuration", err)
 	}
+	log := logger.New(os.Stderr, cfg.LogLevel)
+	slog.SetDefault(log)
 
 	// The asserter automatically rejects incorrectly formatted
 	// requests.
@@ -85,7 +90,13 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	var client *solanago.Client
 	if cfg.Mode == configuration.Online {
 		var err error
-		client, err = solanago.NewClient(cfg.GethURL)
+		client, err = solanago.NewClientWithConfig(solanago.ClientConfig{
+			URLs:                cfg.GethURLs,
+			Commitment:          cfg.Commitment,
+			Retries:             cfg.RPCRetries,
+			MaxSlotLag:          cfg.RPCMaxSlotLag,
+			HealthCheckInterval: cfg.RPCHealthCheckInterval,
+		})
 		if err != nil {
 			return fmt.Errorf("%w: cannot initialize solana client", err)
 		}
@@ -94,7 +105,13 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 
 	router := services.NewBlockchainRouter(cfg, client, asserter)
 
-	loggedRouter := server.LoggerMiddleware(router)
+	// Metrics are served in the Prometheus text format next to the
+	// Rosetta API.
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/", services.MetricsMiddleware(router))
+
+	loggedRouter := services.LoggingMiddleware(log, mux)
 	corsRouter := server.CorsMiddleware(loggedRouter)
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
@@ -105,7 +122,7 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	g.Go(func() error {
-		log.Printf("server listening on port %d", cfg.Port)
+		log.Info("server listening", "port", cfg.Port, "network", cfg.Network, "mode", cfg.Mode)
 		return server.ListenAndServe()
 	})
 
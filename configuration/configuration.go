@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/logger"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
 	ss "github.com/portto/solana-go-sdk/client"
 )
@@ -59,7 +61,8 @@ const (
 
 	// GethEnv is an optional environment variable
 	// used to connect rosetta-solana-go to an already
-	// running geth node.
+	// running geth node. It can list several comma
+	// separated endpoints to fail over between.
 	GethEnv = "RPC_URL"
 
 	// DefaultGethURL is the default URL for
@@ -67,6 +70,96 @@ const (
 	// when GethEnv is not populated.
 	DefaultGethURL = "http://localhost:8545"
 
+	// NonceLeaseEnv is an optional environment variable setting how
+	// long a pooled durable nonce account stays allocated to a
+	// transaction that is not submitted.
+	NonceLeaseEnv = "NONCE_LEASE"
+
+	// DefaultNonceLease is the nonce lease used when NonceLeaseEnv is
+	// not populated.
+	DefaultNonceLease = time.Hour
+
+	// EncodingEnv is an optional environment variable setting the
+	// encoding, base58 or base64, of the transactions returned by
+	// /construction/payloads when the request does not select one.
+	EncodingEnv = "TX_ENCODING"
+
+	// DefaultEncoding is the transaction encoding used when
+	// EncodingEnv is not populated.
+	DefaultEncoding = solanago.Base58Encoding
+
+	// SubmitCommitmentEnv is an optional environment variable setting
+	// the commitment, processed, confirmed or finalized, a transaction
+	// sent by /construction/submit must reach before it is no longer
+	// rebroadcast.
+	SubmitCommitmentEnv = "SUBMIT_COMMITMENT"
+
+	// DefaultSubmitCommitment is the commitment used when
+	// SubmitCommitmentEnv is not populated.
+	DefaultSubmitCommitment = solanago.ConfirmedCommitment
+
+	// RebroadcastIntervalEnv is an optional environment variable
+	// setting how often submitted transactions are checked and
+	// rebroadcast.
+	RebroadcastIntervalEnv = "REBROADCAST_INTERVAL"
+
+	// DefaultRebroadcastInterval is the rebroadcast interval used when
+	// RebroadcastIntervalEnv is not populated.
+	DefaultRebroadcastInterval = 2 * time.Second
+
+	// CommitmentEnv is an optional environment variable setting the
+	// commitment, processed, confirmed or finalized, of the block,
+	// balance, status and metadata reads.
+	CommitmentEnv = "COMMITMENT"
+
+	// DefaultCommitment is the commitment used when CommitmentEnv is
+	// not populated.
+	DefaultCommitment = solanago.FinalizedCommitment
+
+	// BlockCacheSizeEnv is an optional environment variable setting
+	// how many converted blocks /block keeps in memory. 0 disables the
+	// block cache.
+	BlockCacheSizeEnv = "BLOCK_CACHE_SIZE"
+
+	// DefaultBlockCacheSize is the block cache size used when
+	// BlockCacheSizeEnv is not populated.
+	DefaultBlockCacheSize = 100
+
+	// BlockCacheDirEnv is an optional environment variable setting the
+	// directory finalized blocks evicted from memory are spilled to.
+	BlockCacheDirEnv = "BLOCK_CACHE_DIR"
+
+	// BlockCacheDiskSizeEnv is an optional environment variable setting
+	// how many blocks are kept in BlockCacheDirEnv.
+	BlockCacheDiskSizeEnv = "BLOCK_CACHE_DISK_SIZE"
+
+	// DefaultBlockCacheDiskSize is the number of spilled blocks kept
+	// when BlockCacheDiskSizeEnv is not populated.
+	DefaultBlockCacheDiskSize = 10000
+
+	// RPCRetriesEnv is an optional environment variable setting how
+	// many times a request to the node failing with a retriable error
+	// is retried.
+	RPCRetriesEnv = "RPC_RETRIES"
+
+	// RPCMaxSlotLagEnv is an optional environment variable setting how
+	// many slots an endpoint can be behind the most advanced one before
+	// requests prefer other endpoints.
+	RPCMaxSlotLagEnv = "RPC_MAX_SLOT_LAG"
+
+	// RPCHealthCheckIntervalEnv is an optional environment variable
+	// setting how often the health and slot of the endpoints are
+	// checked.
+	RPCHealthCheckIntervalEnv = "RPC_HEALTH_CHECK_INTERVAL"
+
+	// LogLevelEnv is an optional environment variable setting the
+	// lowest level of the entries logged: debug, info, warn or error.
+	LogLevelEnv = "LOG_LEVEL"
+
+	// DefaultLogLevel is the log level used when LogLevelEnv is not
+	// populated.
+	DefaultLogLevel = logger.InfoLevel
+
 	// MiddlewareVersion is the version of rosetta-solanago.
 	MiddlewareVersion = "0.0.4"
 )
@@ -77,9 +170,22 @@ type Configuration struct {
 	Network                *types.NetworkIdentifier
 	GenesisBlockIdentifier *types.BlockIdentifier
 	GethURL                string
+	GethURLs               []string
 	RemoteGeth             bool
 	Port                   int
 	GethArguments          string
+	NonceLease             time.Duration
+	Encoding               string
+	SubmitCommitment       string
+	RebroadcastInterval    time.Duration
+	Commitment             string
+	BlockCacheSize         int
+	BlockCacheDir          string
+	BlockCacheDiskSize     int
+	RPCRetries             int
+	RPCMaxSlotLag          uint64
+	RPCHealthCheckInterval time.Duration
+	LogLevel               logger.Level
 }
 
 // LoadConfiguration attempts to create a new Configuration
@@ -127,6 +233,24 @@ func LoadConfiguration() (*Configuration, error) {
 	default:
 		return nil, fmt.Errorf("%s is not a valid network", networkValue)
 	}
+	config.LogLevel = DefaultLogLevel
+	if levelValue := os.Getenv(LogLevelEnv); len(levelValue) > 0 {
+		level, err := logger.ParseLevel(levelValue)
+		if err != nil {
+			return nil, err
+		}
+		config.LogLevel = level
+	}
+
+	// Offline construction encodes transactions too.
+	config.Encoding = DefaultEncoding
+	if encodingValue := os.Getenv(EncodingEnv); len(encodingValue) > 0 {
+		if !solanago.IsValidEncoding(encodingValue) {
+			return nil, fmt.Errorf("unsupported transaction encoding %s", encodingValue)
+		}
+		config.Encoding = encodingValue
+	}
+
 	if config.Mode == Offline {
 		return config, nil
 	}
@@ -135,6 +259,10 @@ func LoadConfiguration() (*Configuration, error) {
 		config.RemoteGeth = true
 		config.GethURL = envGethURL
 	}
+	config.GethURLs = solanago.SplitURLs(config.GethURL)
+	if len(config.GethURLs) == 0 {
+		return nil, fmt.Errorf("unable to parse rpc url %s", config.GethURL)
+	}
 
 	portValue := os.Getenv(PortEnv)
 	if len(portValue) == 0 {
@@ -147,5 +275,111 @@ func LoadConfiguration() (*Configuration, error) {
 	}
 	config.Port = port
 
+	config.NonceLease = DefaultNonceLease
+	if leaseValue := os.Getenv(NonceLeaseEnv); len(leaseValue) > 0 {
+		lease, err := time.ParseDuration(leaseValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse nonce lease %s", err, leaseValue)
+		}
+		if lease <= 0 {
+			return nil, fmt.Errorf("nonce lease %s must be positive", leaseValue)
+		}
+		config.NonceLease = lease
+	}
+
+	config.SubmitCommitment = DefaultSubmitCommitment
+	if commitmentValue := os.Getenv(SubmitCommitmentEnv); len(commitmentValue) > 0 {
+		if !solanago.IsValidCommitment(commitmentValue) {
+			return nil, fmt.Errorf("unsupported submit commitment %s", commitmentValue)
+		}
+		config.SubmitCommitment = commitmentValue
+	}
+
+	config.RebroadcastInterval = DefaultRebroadcastInterval
+	if intervalValue := os.Getenv(RebroadcastIntervalEnv); len(intervalValue) > 0 {
+		interval, err := time.ParseDuration(intervalValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse rebroadcast interval %s", err, intervalValue)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("rebroadcast interval %s must be positive", intervalValue)
+		}
+		config.RebroadcastInterval = interval
+	}
+
+	config.Commitment = DefaultCommitment
+	if commitmentValue := os.Getenv(CommitmentEnv); len(commitmentValue) > 0 {
+		if !solanago.IsValidCommitment(commitmentValue) {
+			return nil, fmt.Errorf("unsupported commitment %s", commitmentValue)
+		}
+		config.Commitment = commitmentValue
+	}
+
+	config.BlockCacheSize = DefaultBlockCacheSize
+	if sizeValue := os.Getenv(BlockCacheSizeEnv); len(sizeValue) > 0 {
+		size, err := strconv.Atoi(sizeValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse block cache size %s", err, sizeValue)
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("block cache size %s must be non-negative", sizeValue)
+		}
+		config.BlockCacheSize = size
+	}
+
+	config.BlockCacheDir = os.Getenv(BlockCacheDirEnv)
+	if len(config.BlockCacheDir) > 0 {
+		if err := os.MkdirAll(config.BlockCacheDir, 0o755); err != nil {
+			return nil, fmt.Errorf("%w: unable to create block cache dir %s", err, config.BlockCacheDir)
+		}
+	}
+	config.BlockCacheDiskSize = DefaultBlockCacheDiskSize
+	if sizeValue := os.Getenv(BlockCacheDiskSizeEnv); len(sizeValue) > 0 {
+		size, err := strconv.Atoi(sizeValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse block cache disk size %s", err, sizeValue)
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("block cache disk size %s must be positive", sizeValue)
+		}
+		config.BlockCacheDiskSize = size
+	}
+
+	config.RPCRetries = solanago.DefaultRetries
+	if retriesValue := os.Getenv(RPCRetriesEnv); len(retriesValue) > 0 {
+		retries, err := strconv.Atoi(retriesValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse rpc retries %s", err, retriesValue)
+		}
+		if retries < 0 {
+			return nil, fmt.Errorf("rpc retries %s must be non-negative", retriesValue)
+		}
+		config.RPCRetries = retries
+	}
+
+	config.RPCMaxSlotLag = solanago.DefaultMaxSlotLag
+	if lagValue := os.Getenv(RPCMaxSlotLagEnv); len(lagValue) > 0 {
+		lag, err := strconv.ParseUint(lagValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse rpc max slot lag %s", err, lagValue)
+		}
+		if lag == 0 {
+			return nil, fmt.Errorf("rpc max slot lag %s must be positive", lagValue)
+		}
+		config.RPCMaxSlotLag = lag
+	}
+
+	config.RPCHealthCheckInterval = solanago.DefaultHealthCheckInterval
+	if intervalValue := os.Getenv(RPCHealthCheckIntervalEnv); len(intervalValue) > 0 {
+		interval, err := time.ParseDuration(intervalValue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse rpc health check interval %s", err, intervalValue)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("rpc health check interval %s must be positive", intervalValue)
+		}
+		config.RPCHealthCheckInterval = interval
+	}
+
 	return config, nil
 }
@@ -0,0 +1,106 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/test-go/testify/assert"
+)
+
+// setenv sets the environment variables of env for the duration of t.
+func setenv(t *testing.T, env map[string]string) {
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestLoadConfigurationOffline(t *testing.T) {
+	setenv(t, map[string]string{
+		ModeEnv:     string(Offline),
+		NetworkEnv:  Devnet,
+		PortEnv:     "",
+		EncodingEnv: solanago.Base64Encoding,
+	})
+
+	config, err := LoadConfiguration()
+	assert.NoError(t, err)
+	assert.Equal(t, Offline, config.Mode)
+	assert.Equal(t, solanago.DevnetNetwork, config.Network.Network)
+	assert.Equal(t, solanago.Base64Encoding, config.Encoding)
+
+	setenv(t, map[string]string{EncodingEnv: "base32"})
+	_, err = LoadConfiguration()
+	assert.Error(t, err)
+
+	setenv(t, map[string]string{EncodingEnv: ""})
+	config, err = LoadConfiguration()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultEncoding, config.Encoding)
+}
+
+func TestLoadConfigurationOnline(t *testing.T) {
+	setenv(t, map[string]string{
+		ModeEnv:                   string(Online),
+		NetworkEnv:                Devnet,
+		PortEnv:                   "8080",
+		RPCRetriesEnv:             "0",
+		RPCMaxSlotLagEnv:          "20",
+		RPCHealthCheckIntervalEnv: "1s",
+	})
+
+	config, err := LoadConfiguration()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, config.RPCRetries)
+	assert.Equal(t, uint64(20), config.RPCMaxSlotLag)
+	assert.Equal(t, time.Second, config.RPCHealthCheckInterval)
+
+	for _, tt := range []struct {
+		env   string
+		value string
+		err   string
+	}{
+		{RPCRetriesEnv, "-1", "rpc retries -1 must be non-negative"},
+		{RPCMaxSlotLagEnv, "0", "rpc max slot lag 0 must be positive"},
+		{RPCHealthCheckIntervalEnv, "0s", "rpc health check interval 0s must be positive"},
+		{BlockCacheSizeEnv, "-1", "block cache size -1 must be non-negative"},
+		{BlockCacheDiskSizeEnv, "0", "block cache disk size 0 must be positive"},
+	} {
+		valid := os.Getenv(tt.env)
+		setenv(t, map[string]string{tt.env: tt.value})
+		_, err := LoadConfiguration()
+		assert.EqualError(t, err, tt.err)
+
+		// Values that cannot be parsed wrap the parse error.
+		setenv(t, map[string]string{tt.env: "x"})
+		_, err = LoadConfiguration()
+		assert.Error(t, err)
+		assert.NotNil(t, errors.Unwrap(err), tt.env)
+		setenv(t, map[string]string{tt.env: valid})
+	}
+}
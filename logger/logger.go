@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logger builds the log/slog loggers writing JSON entries, one
+// per line, and carries loggers with request fields through contexts.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Level is the severity of a log entry.
+type Level = slog.Level
+
+const (
+	// DebugLevel entries trace requests to the node.
+	DebugLevel = slog.LevelDebug
+
+	// InfoLevel entries trace requests served.
+	InfoLevel = slog.LevelInfo
+
+	// WarnLevel entries report errors that are recovered from.
+	WarnLevel = slog.LevelWarn
+
+	// ErrorLevel entries report errors that are not.
+	ErrorLevel = slog.LevelError
+)
+
+// ParseLevel returns the level named s: debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel} {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %s", s)
+}
+
+// New returns a logger of the entries of at least level to out, as
+// JSON objects with their level in lower case and their durations as
+// strings.
+func New(out io.Writer, level Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: replaceAttr,
+	}))
+}
+
+// Discard returns a logger dropping every entry.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch {
+	case len(groups) == 0 && a.Key == slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(strings.ToLower(level.String()))
+		}
+	case a.Value.Kind() == slog.KindDuration:
+		a.Value = slog.StringValue(a.Value.Duration().String())
+	}
+	return a
+}
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger of ctx, or slog.Default if it has
+// none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/test-go/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, InfoLevel)
+
+	reqLogger := l.With("request_id", "abc")
+	reqLogger.Debug("skipped")
+	reqLogger.Info("served", "status", 200, "duration", 1500*time.Millisecond)
+	reqLogger.Warn("failed", "error", errors.New("boom"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.NotEmpty(t, entry["time"])
+	delete(entry, "time")
+	assert.Equal(t, map[string]interface{}{
+		"level":      "info",
+		"msg":        "served",
+		"request_id": "abc",
+		"status":     float64(200),
+		"duration":   "1.5s",
+	}, entry)
+	entry = nil
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "boom", entry["error"])
+
+	ctx := WithContext(context.Background(), reqLogger)
+	assert.Equal(t, reqLogger, FromContext(ctx))
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+	assert.False(t, Discard().Enabled(context.Background(), ErrorLevel))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, WarnLevel, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -48,13 +48,19 @@ func (s *AccountAPIService) AccountBalance(
 	if s.config.Mode != configuration.Online {
 		return nil, ErrUnavailableOffline
 	}
+	// The request has no metadata of its own, the commitment is
+	// overridden in the metadata of the account identifier.
+	ctx, terr := withCommitment(ctx, request.AccountIdentifier.Metadata)
+	if terr != nil {
+		return nil, terr
+	}
 	balanceResponse, err := s.client.Balance(
 		ctx,
 		request.AccountIdentifier,
 		request.BlockIdentifier,
 	)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, nodeErr(ErrGeth, err)
 	}
 
 	return balanceResponse, nil
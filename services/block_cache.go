@@ -0,0 +1,306 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+)
+
+// unfinalizedBlockTTL is how long a block read below finalized
+// commitment is served from the cache. The cluster finalizes a block
+// well within it, or the block was dropped.
+const unfinalizedBlockTTL = 30 * time.Second
+
+// blockCache keeps the last size blocks converted by /block, keyed by
+// slot. Finalized blocks are kept until they are evicted, to dir if
+// set, where at most diskSize blocks are kept. Blocks read below
+// finalized commitment are only kept in memory, only served to reads
+// below finalized, and invalidated after unfinalizedBlockTTL or when
+// the slot is read with another hash.
+type blockCache struct {
+	size     int
+	dir      string
+	diskSize int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lru       *list.List
+	blocks    map[int64]*list.Element
+	disk      map[int64]string
+	diskOrder []int64
+	stats     BlockCacheStats
+}
+
+// cachedBlock is a block of the cache. expires is zero for finalized
+// blocks.
+type cachedBlock struct {
+	block   *types.Block
+	expires time.Time
+}
+
+// newBlockCache returns a cache of size blocks, spilling up to diskSize
+// finalized blocks to dir if set. The blocks already in dir are kept.
+// It returns nil if size is not positive.
+func newBlockCache(size int, dir string, diskSize int) (*blockCache, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	c := &blockCache{
+		size:     size,
+		dir:      dir,
+		diskSize: diskSize,
+		now:      time.Now,
+		lru:      list.New(),
+		blocks:   make(map[int64]*list.Element),
+		disk:     make(map[int64]string),
+	}
+	if len(dir) == 0 {
+		return c, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if slot, hash, ok := parseBlockFile(f.Name()); ok {
+			c.disk[slot] = hash
+			c.diskOrder = append(c.diskOrder, slot)
+		}
+	}
+	sort.Slice(c.diskOrder, func(i, j int) bool { return c.diskOrder[i] < c.diskOrder[j] })
+	c.trimDisk()
+	return c, nil
+}
+
+// get returns the cached block of id, if it can be served to a read at
+// commitment. Blocks are only looked up by index: the node cannot read a
+// block by hash, so a hash-only identifier is not served either.
+func (c *blockCache) get(id *types.PartialBlockIdentifier, commitment string) (*types.Block, bool) {
+	if id == nil || id.Index == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slot := *id.Index
+	if e, ok := c.blocks[slot]; ok {
+		v := e.Value.(*cachedBlock)
+		if id.Hash != nil && *id.Hash != v.block.BlockIdentifier.Hash {
+			c.stats.Misses++
+			return nil, false
+		}
+		if !v.expires.IsZero() {
+			if c.now().After(v.expires) {
+				c.remove(slot)
+				c.stats.Invalidations++
+				c.stats.Misses++
+				return nil, false
+			}
+			if isFinalized(commitment) {
+				c.stats.Misses++
+				return nil, false
+			}
+		}
+		c.lru.MoveToFront(e)
+		c.stats.Hits++
+		return v.block, true
+	}
+
+	block, err := c.read(slot, id.Hash)
+	if err != nil || block == nil {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.DiskHits++
+	c.store(slot, &cachedBlock{block: block})
+	return block, true
+}
+
+// transaction returns the transaction hash of the cached block of id,
+// if it can be served to a read at commitment.
+func (c *blockCache) transaction(
+	id *types.BlockIdentifier,
+	hash string,
+	commitment string,
+) (*types.Transaction, bool) {
+	if id == nil {
+		return nil, false
+	}
+	index, blockHash := id.Index, id.Hash
+	block, ok := c.get(&types.PartialBlockIdentifier{Index: &index, Hash: &blockHash}, commitment)
+	if !ok {
+		return nil, false
+	}
+	for _, tx := range block.Transactions {
+		if tx.TransactionIdentifier.Hash == hash {
+			return tx, true
+		}
+	}
+	return nil, false
+}
+
+// add caches block, read at commitment.
+func (c *blockCache) add(block *types.Block, commitment string) {
+	if block == nil || block.BlockIdentifier == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slot := block.BlockIdentifier.Index
+	v := &cachedBlock{block: block}
+	if !isFinalized(commitment) {
+		v.expires = c.now().Add(unfinalizedBlockTTL)
+	}
+	if e, ok := c.blocks[slot]; ok {
+		cached := e.Value.(*cachedBlock)
+		if cached.expires.IsZero() && !v.expires.IsZero() {
+			// A finalized block is not replaced by a block that is not.
+			return
+		}
+		if cached.block.BlockIdentifier.Hash != block.BlockIdentifier.Hash {
+			c.stats.Invalidations++
+		}
+		c.remove(slot)
+	}
+	c.store(slot, v)
+}
+
+// Stats returns the statistics of the cache.
+func (c *blockCache) Stats() BlockCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Blocks = c.lru.Len()
+	stats.DiskBlocks = len(c.diskOrder)
+	return stats
+}
+
+// store adds v to the memory of the cache and evicts the least recently
+// used blocks beyond size, spilling finalized ones to the disk.
+func (c *blockCache) store(slot int64, v *cachedBlock) {
+	c.blocks[slot] = c.lru.PushFront(v)
+	for c.lru.Len() > c.size {
+		e := c.lru.Back()
+		evicted := e.Value.(*cachedBlock)
+		c.remove(evicted.block.BlockIdentifier.Index)
+		c.stats.Evictions++
+		if evicted.expires.IsZero() {
+			c.spill(evicted.block)
+		}
+	}
+}
+
+// remove removes the block of slot from the memory of the cache.
+func (c *blockCache) remove(slot int64) {
+	e, ok := c.blocks[slot]
+	if !ok {
+		return
+	}
+	c.lru.Remove(e)
+	delete(c.blocks, slot)
+}
+
+// spill writes the finalized block to the disk, if enabled. A block
+// that cannot be written is dropped.
+func (c *blockCache) spill(block *types.Block) {
+	slot, hash := block.BlockIdentifier.Index, block.BlockIdentifier.Hash
+	if len(c.dir) == 0 || c.onDisk(slot, hash) {
+		return
+	}
+	b, err := json.Marshal(block)
+	if err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(c.dir, blockFile(slot, hash)), b, 0o644); err != nil {
+		return
+	}
+	c.disk[slot] = hash
+	c.diskOrder = append(c.diskOrder, slot)
+	c.trimDisk()
+}
+
+// trimDisk removes the oldest spilled blocks beyond diskSize.
+func (c *blockCache) trimDisk() {
+	for len(c.diskOrder) > c.diskSize {
+		slot := c.diskOrder[0]
+		hash := c.disk[slot]
+		c.diskOrder = c.diskOrder[1:]
+		delete(c.disk, slot)
+		os.Remove(filepath.Join(c.dir, blockFile(slot, hash)))
+	}
+}
+
+// read returns the spilled block of slot, and hash if set.
+func (c *blockCache) read(slot int64, hash *string) (*types.Block, error) {
+	diskHash, ok := c.disk[slot]
+	if !ok || (hash != nil && *hash != diskHash) {
+		return nil, nil
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(c.dir, blockFile(slot, diskHash)))
+	if err != nil {
+		return nil, err
+	}
+	var block types.Block
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (c *blockCache) onDisk(slot int64, hash string) bool {
+	diskHash, ok := c.disk[slot]
+	return ok && diskHash == hash
+}
+
+// isFinalized returns true if reads at commitment are finalized. The
+// node reads at finalized when no commitment is set.
+func isFinalized(commitment string) bool {
+	return len(commitment) == 0 || commitment == solanago.FinalizedCommitment
+}
+
+func blockFile(slot int64, hash string) string {
+	return fmt.Sprintf("%d_%s.json", slot, hash)
+}
+
+// parseBlockFile returns the slot and hash of the block spilled to the
+// file name.
+func parseBlockFile(name string) (int64, string, bool) {
+	parts := strings.SplitN(strings.TrimSuffix(name, ".json"), "_", 2)
+	if len(parts) != 2 || !strings.HasSuffix(name, ".json") {
+		return 0, "", false
+	}
+	slot, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return slot, parts[1], true
+}
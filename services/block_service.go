@@ -16,6 +16,7 @@ package services
 
 import (
 	"context"
+	"log/slog"
 
 	"github.com/imerkle/rosetta-solana-go/configuration"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
@@ -27,6 +28,9 @@ import (
 type BlockAPIService struct {
 	config *configuration.Configuration
 	client *solanago.Client
+
+	// cache keeps converted blocks, nil when disabled.
+	cache *blockCache
 }
 
 // NewBlockAPIService creates a new instance of a BlockAPIService.
@@ -34,9 +38,17 @@ func NewBlockAPIService(
 	cfg *configuration.Configuration,
 	client *solanago.Client,
 ) *BlockAPIService {
+	cache, err := newBlockCache(cfg.BlockCacheSize, cfg.BlockCacheDir, cfg.BlockCacheDiskSize)
+	if err != nil {
+		// The directory is checked by LoadConfiguration, keep the
+		// blocks in memory only if it became unusable since.
+		slog.Default().Warn("unable to use block cache dir", "dir", cfg.BlockCacheDir, "error", err)
+		cache, _ = newBlockCache(cfg.BlockCacheSize, "", 0)
+	}
 	return &BlockAPIService{
 		config: cfg,
 		client: client,
+		cache:  cache,
 	}
 }
 
@@ -49,9 +61,21 @@ func (s *BlockAPIService) Block(
 		return nil, ErrUnavailableOffline
 	}
 
+	commitment := s.client.BlockCommitment(ctx)
+	if s.cache != nil {
+		if block, ok := s.cache.get(request.BlockIdentifier, commitment); ok {
+			return &types.BlockResponse{
+				Block: block,
+			}, nil
+		}
+	}
+
 	block, err := s.client.Block(ctx, request.BlockIdentifier)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, nodeErr(ErrGeth, err)
+	}
+	if s.cache != nil {
+		s.cache.add(block, commitment)
 	}
 
 	return &types.BlockResponse{
@@ -68,9 +92,18 @@ func (s *BlockAPIService) BlockTransaction(
 		return nil, ErrUnavailableOffline
 	}
 
+	if s.cache != nil {
+		hash := request.TransactionIdentifier.Hash
+		if tx, ok := s.cache.transaction(request.BlockIdentifier, hash, s.client.BlockCommitment(ctx)); ok {
+			return &types.BlockTransactionResponse{
+				Transaction: tx,
+			}, nil
+		}
+	}
+
 	tx, err := s.client.BlockTransaction(ctx, request)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, nodeErr(ErrGeth, err)
 	}
 	return &types.BlockTransactionResponse{
 		Transaction: tx,
@@ -16,6 +16,7 @@ package services
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/imerkle/rosetta-solana-go/configuration"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
@@ -27,6 +28,12 @@ import (
 type CallAPIService struct {
 	config *configuration.Configuration
 	client *solanago.Client
+
+	// submitter tracks the transactions sent by /construction/submit.
+	submitter *submitter
+
+	// blockCache keeps the blocks converted by /block.
+	blockCache *blockCache
 }
 
 // NewCallAPIService creates a new instance of a CallAPIService.
@@ -45,10 +52,171 @@ func (s *CallAPIService) Call(
 	if s.config.Mode != configuration.Online {
 		return nil, ErrUnavailableOffline
 	}
+	switch request.Method {
+	case solanago.CallSimulate:
+		return s.simulate(ctx, request)
+	case solanago.CallStatus:
+		return s.status(ctx, request)
+	case solanago.CallBlockCacheStats:
+		return s.blockCacheStats()
+	}
+
 	response, err := s.client.Call(ctx, request)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, callErr(err)
 	}
 
 	return response, nil
 }
+
+// blockCacheStats returns the statistics of the block cache, all zero
+// when it is disabled.
+func (s *CallAPIService) blockCacheStats() (*types.CallResponse, *types.Error) {
+	var stats BlockCacheStats
+	if s.blockCache != nil {
+		stats = s.blockCache.Stats()
+	}
+	result, err := marshalJSONMap(stats)
+	if err != nil {
+		return nil, wrapErr(ErrCallOutputMarshal, err)
+	}
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// callErr returns the error of a method called on the node. Methods
+// the node does not know and invalid params are reported as invalid
+// call methods and parameters.
+func callErr(err error) *types.Error {
+	switch solanago.ClassifyError(err) {
+	case solanago.ErrMethodNotFound:
+		return rpcDetails(wrapErr(ErrCallMethodInvalid, err), err)
+	case solanago.ErrInvalidParams:
+		return rpcDetails(wrapErr(ErrCallParametersInvalid, err), err)
+	default:
+		return nodeErr(ErrGeth, err)
+	}
+}
+
+// SimulateParameters are the /call parameters of solanago.CallSimulate.
+type SimulateParameters struct {
+	Transaction string `json:"transaction"`
+	SigVerify   bool   `json:"sig_verify"`
+}
+
+// simulate runs a transaction returned by /construction/payloads or
+// /construction/combine against the node and predicts its balance changes.
+func (s *CallAPIService) simulate(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	var params SimulateParameters
+	if err := unmarshalJSONMap(request.Parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+	if len(params.Transaction) == 0 {
+		return nil, wrapErr(ErrCallParametersInvalid, fmt.Errorf("transaction is required"))
+	}
+	tx, err := solanago.GetTxFromStr(params.Transaction)
+	if err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+
+	// Unsigned transactions can only be simulated without
+	// signature verification.
+	sigVerify := params.SigVerify && solanago.IsSigned(tx)
+	sim, err := s.client.Simulate(ctx, tx, sigVerify)
+	if err != nil {
+		return nil, nodeErr(ErrGeth, err)
+	}
+
+	status := solanago.SuccessStatus
+	if sim.Err != nil {
+		status = solanago.FailureStatus
+	}
+	result, err := marshalJSONMap(SimulateResult{
+		Status:        status,
+		Err:           sim.Err,
+		Logs:          sim.Logs,
+		UnitsConsumed: sim.UnitsConsumed,
+		Slot:          sim.Slot,
+		Operations:    solanago.GetRosOperationsFromSimulation(sim),
+	})
+	if err != nil {
+		return nil, wrapErr(ErrCallOutputMarshal, err)
+	}
+
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
+
+// StatusParameters are the /call parameters of solanago.CallStatus.
+type StatusParameters struct {
+	Hash string `json:"hash"`
+}
+
+// status reports the status of a transaction sent by
+// /construction/submit. Transactions the submitter does not track are
+// looked up on the node.
+func (s *CallAPIService) status(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	var params StatusParameters
+	if err := unmarshalJSONMap(request.Parameters, &params); err != nil {
+		return nil, wrapErr(ErrCallParametersInvalid, err)
+	}
+	if len(params.Hash) == 0 {
+		return nil, wrapErr(ErrCallParametersInvalid, fmt.Errorf("hash is required"))
+	}
+
+	var status StatusResult
+	tracked := false
+	if s.submitter != nil {
+		status, tracked = s.submitter.status(params.Hash)
+	}
+	if !tracked {
+		statuses, err := s.client.GetSignatureStatuses(ctx, []string{params.Hash})
+		if err != nil {
+			return nil, nodeErr(ErrGeth, err)
+		}
+		status = StatusResult{Hash: params.Hash, Status: solanago.TransactionUnknown}
+		if len(statuses) > 0 && statuses[0] != nil {
+			commitment := s.config.SubmitCommitment
+			if len(commitment) == 0 {
+				commitment = configuration.DefaultSubmitCommitment
+			}
+			status.Status = solanago.TransactionPending
+			if statuses[0].Err != nil {
+				status.Status = solanago.TransactionFailed
+			} else if solanago.CommitmentRank(statuses[0].ConfirmationStatus) >= solanago.CommitmentRank(commitment) {
+				status.Status = solanago.TransactionConfirmed
+			}
+			status.Commitment = statuses[0].ConfirmationStatus
+			status.Slot = statuses[0].Slot
+			status.Err = statuses[0].Err
+			// Read the transaction at the commitment it reached.
+			readCtx := solanago.WithCommitment(ctx, statuses[0].ConfirmationStatus)
+			outcome, err := s.client.GetTransactionOutcome(readCtx, params.Hash)
+			if err != nil {
+				return nil, nodeErr(ErrGeth, err)
+			}
+			if outcome != nil {
+				status.Fee = outcome.Fee
+			}
+		}
+	}
+
+	result, err := marshalJSONMap(status)
+	if err != nil {
+		return nil, wrapErr(ErrCallOutputMarshal, err)
+	}
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: false,
+	}, nil
+}
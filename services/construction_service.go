@@ -15,12 +15,15 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/imerkle/rosetta-solana-go/configuration"
+	"github.com/imerkle/rosetta-solana-go/logger"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
 	"github.com/imerkle/rosetta-solana-go/solana/operations"
 	"github.com/mitchellh/copystructure"
@@ -34,8 +37,10 @@ import (
 
 // ConstructionAPIService implements the server.ConstructionAPIServicer interface.
 type ConstructionAPIService struct {
-	config *configuration.Configuration
-	client *solanago.Client
+	config    *configuration.Configuration
+	client    *solanago.Client
+	noncePool *noncePool
+	submitter *submitter
 }
 
 // NewConstructionAPIService creates a new instance of a ConstructionAPIService.
@@ -43,9 +48,23 @@ func NewConstructionAPIService(
 	cfg *configuration.Configuration,
 	client *solanago.Client,
 ) *ConstructionAPIService {
+	lease := cfg.NonceLease
+	if lease == 0 {
+		lease = configuration.DefaultNonceLease
+	}
+	commitment := cfg.SubmitCommitment
+	if len(commitment) == 0 {
+		commitment = configuration.DefaultSubmitCommitment
+	}
+	interval := cfg.RebroadcastInterval
+	if interval == 0 {
+		interval = configuration.DefaultRebroadcastInterval
+	}
 	return &ConstructionAPIService{
-		config: cfg,
-		client: client,
+		config:    cfg,
+		client:    client,
+		noncePool: newNoncePool(client, lease),
+		submitter: newSubmitter(client, commitment, interval),
 	}
 }
 
@@ -55,6 +74,9 @@ func (s *ConstructionAPIService) ConstructionDerive(
 	request *types.ConstructionDeriveRequest,
 ) (*types.ConstructionDeriveResponse, *types.Error) {
 	addr := base58.Encode(request.PublicKey.Bytes)
+	if _, ok := request.Metadata[solanago.SeedKey]; ok {
+		return deriveWithSeed(addr, request.Metadata)
+	}
 	return &types.ConstructionDeriveResponse{
 		AccountIdentifier: &types.AccountIdentifier{
 			Address: addr,
@@ -62,39 +84,154 @@ func (s *ConstructionAPIService) ConstructionDerive(
 	}, nil
 }
 
+// deriveWithSeed derives the address of the seed in metadata from a
+// base key and an owner program, without querying the node. The base
+// defaults to the public key and the owner to the System program.
+func deriveWithSeed(
+	publicKey string,
+	metadata map[string]interface{},
+) (*types.ConstructionDeriveResponse, *types.Error) {
+	seed, ok := metadata[solanago.SeedKey].(string)
+	if !ok {
+		return nil, wrapErr(ErrInvalidSeed, fmt.Errorf("seed must be a string"))
+	}
+	if len(seed) > solanago.MaxSeedLength {
+		return nil, wrapErrDetails(ErrInvalidSeed, map[string]interface{}{
+			"length":     len(seed),
+			"max_length": solanago.MaxSeedLength,
+		})
+	}
+	base := publicKey
+	if v, ok := metadata[solanago.BaseKey].(string); ok && len(v) > 0 {
+		base = v
+	}
+	owner := common.SystemProgramID.ToBase58()
+	if v, ok := metadata[solanago.OwnerKey].(string); ok && len(v) > 0 {
+		owner = v
+	}
+	if !solanago.IsValidAddress(base) {
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("invalid base %s", base))
+	}
+	if !solanago.IsValidAddress(owner) {
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("invalid owner %s", owner))
+	}
+
+	addr := common.CreateWithSeed(common.PublicKeyFromString(base), seed, common.PublicKeyFromString(owner))
+	return &types.ConstructionDeriveResponse{
+		AccountIdentifier: &types.AccountIdentifier{
+			Address: addr.ToBase58(),
+		},
+		Metadata: map[string]interface{}{
+			solanago.BaseKey:  base,
+			solanago.SeedKey:  seed,
+			solanago.OwnerKey: owner,
+		},
+	}, nil
+}
+
 // ConstructionPreprocess implements the /construction/preprocess
 // endpoint.
 func (s *ConstructionAPIService) ConstructionPreprocess(
 	ctx context.Context,
 	request *types.ConstructionPreprocessRequest,
 ) (*types.ConstructionPreprocessResponse, *types.Error) {
-	withNonce, _ := solanago.GetWithNonce(request.Metadata)
+	withNonce, hasNonce := solanago.GetWithNonce(request.Metadata)
+	split := solanago.GetSplit(request.Metadata)
+	feePayer, hasFeePayer := solanago.GetFeePayer(request.Metadata)
+	if hasFeePayer && !solanago.IsValidAddress(feePayer) {
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("invalid fee payer %s", feePayer))
+	}
+	encoding, hasEncoding := solanago.GetEncoding(request.Metadata)
+	if hasEncoding && !solanago.IsValidEncoding(encoding) {
+		return nil, wrapErr(ErrUnsupportedEncoding, fmt.Errorf("unsupported encoding %s", encoding))
+	}
+	commitment, hasCommitment := solanago.GetCommitment(request.Metadata)
+	if hasCommitment && !solanago.IsValidCommitment(commitment) {
+		return nil, wrapErr(ErrUnsupportedCommitment, fmt.Errorf("unsupported commitment %s", commitment))
+	}
 
-	var matchedOperationHashMap map[int64]bool = make(map[int64]bool)
+	merged, terr := matchOperations(request.Operations)
+	if terr != nil {
+		return nil, terr
+	}
 
 	var SplSystemAccMap map[int64]solanago.SplAccounts = make(map[int64]solanago.SplAccounts)
-	for _, op := range request.Operations {
-		var cont bool
-		var matched *types.Operation
-		cont, matched = FindMatch(request.Operations, op, matchedOperationHashMap)
-		if cont {
-			continue
-		}
-		if matched != nil && op.Type == solanago.SplToken__TransferWithSystem {
+	for _, op := range merged {
+		if op.Type == solanago.SplToken__TransferWithSystem && op.Amount != nil {
+			destination, ok := op.Metadata["destination"].(string)
+			if !ok || len(destination) == 0 {
+				return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("operation %d has no destination", op.OperationIdentifier.Index))
+			}
 			SplSystemAccMap[op.OperationIdentifier.Index] = solanago.SplAccounts{
 				Source:      op.Account.Address,
-				Destination: matched.Account.Address,
+				Destination: destination,
 				Mint:        op.Amount.Currency.Symbol,
 			}
-			matchedOperationHashMap[op.OperationIdentifier.Index] = true
+		}
+		if op.Type == solanago.SplToken__WrapSol {
+			SplSystemAccMap[op.OperationIdentifier.Index] = solanago.SplAccounts{
+				Source:      op.Account.Address,
+				Destination: op.Account.Address,
+				Mint:        solanago.NativeMint,
+			}
+		}
+	}
+	sweeps, terr := getSweeps(merged)
+	if terr != nil {
+		return nil, terr
+	}
+	for k, v := range sweeps {
+		if len(v.Mint) > 0 {
+			SplSystemAccMap[k] = solanago.SplAccounts{
+				Source:      v.Source,
+				Destination: v.Destination,
+				Mint:        v.Mint,
+			}
 		}
 	}
 
+	instructions, terr := operationsToInstructions(merged, nil)
+	if terr != nil {
+		return nil, terr
+	}
+	preflight, terr := getPreflight(merged, instructions, feePayer)
+	if terr != nil {
+		return nil, terr
+	}
+	var nonce *solanago.WithNonce
+	if hasNonce {
+		nonce = &withNonce
+	} else if withNonce.Pooled() {
+		nonce = &solanago.WithNonce{Account: placeholderNonceAccount, Authority: withNonce.Authority}
+	}
+	if terr := checkOperations(merged, nonce, feePayer, split); terr != nil {
+		return nil, terr
+	}
+
+	options := map[string]interface{}{
+		solanago.WithNonceKey:       withNonce,
+		solanago.SplSystemAccMapKey: SplSystemAccMap,
+		solanago.PreflightKey:       preflight,
+		solanago.SplitKey:           split,
+	}
+	if hasFeePayer {
+		options[solanago.FeePayerKey] = feePayer
+	}
+	if hasEncoding {
+		options[solanago.EncodingKey] = encoding
+	}
+	if hasCommitment {
+		options[solanago.CommitmentKey] = commitment
+	}
+	if len(sweeps) > 0 {
+		options[solanago.SweepKey] = sweeps
+	}
+	if slot, ok := request.Metadata[solanago.SlotKey]; ok {
+		options[solanago.SlotKey] = slot
+	}
+
 	return &types.ConstructionPreprocessResponse{
-		Options: map[string]interface{}{
-			solanago.WithNonceKey:       withNonce,
-			solanago.SplSystemAccMapKey: SplSystemAccMap,
-		},
+		Options: options,
 	}, nil
 }
 
@@ -102,25 +239,63 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 func (s *ConstructionAPIService) ConstructionMetadata(
 	ctx context.Context,
 	request *types.ConstructionMetadataRequest,
-) (*types.ConstructionMetadataResponse, *types.Error) {
+) (resp *types.ConstructionMetadataResponse, terr *types.Error) {
 	if s.config.Mode != configuration.Online {
 		return nil, ErrUnavailableOffline
 	}
+	ctx, terr = withCommitment(ctx, request.Options)
+	if terr != nil {
+		return nil, terr
+	}
 
 	var hash string
 	var fee ss.FeeCalculator
+	var nonce *solanago.ParsedAccountInfo
 	withNonce, hasNonce := solanago.GetWithNonce(request.Options)
+	if withNonce.Pooled() {
+		authority := withNonce.Authority
+		withNonce.Account, nonce, terr = s.noncePool.allocate(ctx, authority, func(account string) (*solanago.ParsedAccountInfo, *types.Error) {
+			return s.getNonce(ctx, solanago.WithNonce{Account: account, Authority: authority})
+		})
+		if terr != nil {
+			return nil, terr
+		}
+		hasNonce = true
+		defer func() {
+			if terr != nil {
+				s.noncePool.release(withNonce.Account)
+			}
+		}()
+	} else if hasNonce {
+		nonce, terr = s.getNonce(ctx, withNonce)
+		if terr != nil {
+			return nil, terr
+		}
+	}
 	if hasNonce {
-		acc, _ := s.client.Rpc.GetAccountInfoParsed(ctx, withNonce.Account)
-		withNonce.Authority = acc.Data.Nonce.Initialized.Authority
-		hash = acc.Data.Nonce.Initialized.BlockHash
-		fee = acc.Data.Nonce.Initialized.FeeCalculator
+		withNonce.Authority = nonce.Authority
+		hash = nonce.Blockhash
+		lamportsPerSignature, err := nonce.FeeCalculator.LamportsPerSignature.Int64()
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+		fee = ss.FeeCalculator{LamportsPerSignature: uint64(lamportsPerSignature)}
 	} else {
-		recentBlockhash, _ := s.client.Rpc.GetRecentBlockhash(ctx)
+		recentBlockhash, err := s.client.GetRecentBlockhash(ctx)
+		if err != nil {
+			return nil, nodeErr(ErrGeth, err)
+		}
 		hash = recentBlockhash.Blockhash
 		fee = recentBlockhash.FeeCalculator
 	}
 
+	preflight, hasPreflight := solanago.GetPreflight(request.Options)
+	if hasPreflight {
+		if terr := s.checkPreflight(ctx, preflight, fee, withNonce.Authority); terr != nil {
+			return nil, terr
+		}
+	}
+
 	var SplTokenAccMap map[string]solanago.SplAccounts = make(map[string]solanago.SplAccounts)
 
 	if w, ok := request.Options[solanago.SplSystemAccMapKey]; ok {
@@ -131,8 +306,15 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 
 		for k, v := range SplTokenAccMap {
 
-			source, _ := s.client.GetTokenAccountByMint(ctx, v.Source, v.Mint)
-			destination, _ := s.client.GetTokenAccountByMint(ctx, v.Destination, v.Mint)
+			// Accounts the node cannot find are left empty.
+			source, err := s.client.GetTokenAccountByMint(ctx, v.Source, v.Mint)
+			if err != nil {
+				logger.FromContext(ctx).Warn("unable to get token account", "owner", v.Source, "mint", v.Mint, "error", err)
+			}
+			destination, err := s.client.GetTokenAccountByMint(ctx, v.Destination, v.Mint)
+			if err != nil {
+				logger.FromContext(ctx).Warn("unable to get token account", "owner", v.Destination, "mint", v.Mint, "error", err)
+			}
 			SplTokenAccMap[k] = solanago.SplAccounts{
 				Source:      source,
 				Destination: destination,
@@ -141,11 +323,25 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		}
 	}
 
-	meta, _ := marshalJSONMap(ConstructionMetadata{
+	sweeps, terr := s.resolveSweeps(ctx, request.Options, SplTokenAccMap, preflight, fee, withNonce.Authority)
+	if terr != nil {
+		return nil, terr
+	}
+
+	constructionMetadata := ConstructionMetadata{
 		BlockHash:         hash,
 		FeeCalculator:     fee,
 		SplTokenAccMapKey: SplTokenAccMap,
-	})
+		Split:             solanago.GetSplit(request.Options),
+		Sweeps:            sweeps,
+	}
+	constructionMetadata.FeePayer, _ = solanago.GetFeePayer(request.Options)
+	constructionMetadata.Encoding, _ = solanago.GetEncoding(request.Options)
+	constructionMetadata.Commitment = s.client.Commitment(ctx)
+	if hasNonce {
+		constructionMetadata.WithNonce = &withNonce
+	}
+	meta, _ := marshalJSONMap(constructionMetadata)
 
 	return &types.ConstructionMetadataResponse{
 		Metadata: meta,
@@ -173,7 +369,7 @@ func FindMatch(ops []*types.Operation, op *types.Operation, matchedOperationHash
 			continue
 		}
 		if v.Amount != nil {
-			if v.Amount.Currency.Symbol != op.Amount.Currency.Symbol {
+			if op.Amount == nil || v.Amount.Currency.Symbol != op.Amount.Currency.Symbol {
 				continue
 			}
 			if solanago.ValueToBaseAmount(v.Amount.Value) != solanago.ValueToBaseAmount(op.Amount.Value) {
@@ -191,152 +387,429 @@ func FindMatch(ops []*types.Operation, op *types.Operation, matchedOperationHash
 	return false, matched
 }
 
-// ConstructionPayloads implements the /construction/payloads endpoint.
-func (s *ConstructionAPIService) ConstructionPayloads(
-	ctx context.Context,
-	request *types.ConstructionPayloadsRequest,
-) (*types.ConstructionPayloadsResponse, *types.Error) {
-	var instructions []solPTypes.Instruction
-
-	// Convert map to Metadata struct
-	var meta ConstructionMetadata
+// transferGroup is a sender operation and the receiver operations it
+// funds. Operations without a counterpart form a group of their own.
+type transferGroup struct {
+	sender    *types.Operation
+	receivers []*types.Operation
+}
 
-	if err := unmarshalJSONMap(request.Metadata, &meta); err != nil {
-		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+// matchOperations pairs every sender operation with its receivers and
+// returns one merged operation per receiver. A merged operation keeps the
+// sender account, takes the receiver amount and records both addresses
+// as source and destination metadata. It is identified by the index of
+// the receiver operation.
+//
+// Operations linked through RelatedOperations are grouped explicitly.
+// The remaining operations are paired by FindMatch and, for batch
+// transfers, a sender is then grouped with the following unmatched
+// receivers whose amounts sum to its amount.
+func matchOperations(ops []*types.Operation) ([]*types.Operation, *types.Error) {
+	groups, terr := getRelatedGroups(ops)
+	if terr != nil {
+		return nil, terr
 	}
 
 	var matchedOperationHashMap map[int64]bool = make(map[int64]bool)
-	for _, op := range request.Operations {
-		var cont bool
-		var matched *types.Operation
-		cont, matched = FindMatch(request.Operations, op, matchedOperationHashMap)
-		if cont {
+	for index := range groups {
+		matchedOperationHashMap[index] = true
+	}
+	// Only operations moving an amount between two accounts have a
+	// counterpart.
+	for _, op := range ops {
+		if _, ok := matchedOperationHashMap[op.OperationIdentifier.Index]; ok {
+			continue
+		}
+		if op.Amount != nil && solanago.IsBalanceChanging(op.Type) {
 			continue
 		}
+		groups[op.OperationIdentifier.Index] = &transferGroup{sender: op}
+		matchedOperationHashMap[op.OperationIdentifier.Index] = true
+	}
 
-		if matched == nil && op.Amount != nil {
-			return nil, wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("Invalid Operation Request. Please check format"))
+	for _, op := range ops {
+		cont, matched := FindMatch(ops, op, matchedOperationHashMap)
+		if cont || matched == nil {
+			continue
 		}
+		group := &transferGroup{sender: op, receivers: []*types.Operation{matched}}
+		if isNegativeAmount(matched.Amount) {
+			group = &transferGroup{sender: matched, receivers: []*types.Operation{op}}
+		}
+		for _, v := range []*types.Operation{op, matched} {
+			groups[v.OperationIdentifier.Index] = group
+			matchedOperationHashMap[v.OperationIdentifier.Index] = true
+		}
+	}
 
-		opCopy, err := copystructure.Copy(*op)
-		if err != nil {
-			return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("Cannot deep copy operations"))
+	for _, op := range ops {
+		if _, ok := matchedOperationHashMap[op.OperationIdentifier.Index]; ok {
+			continue
+		}
+		if !isNegativeAmount(op.Amount) || !isBatchTransfer(op.Type) {
+			continue
+		}
+		receivers := findBatchReceivers(ops, op, matchedOperationHashMap)
+		if receivers == nil {
+			continue
+		}
+		group := &transferGroup{sender: op, receivers: receivers}
+		for _, v := range append([]*types.Operation{op}, receivers...) {
+			groups[v.OperationIdentifier.Index] = group
+			matchedOperationHashMap[v.OperationIdentifier.Index] = true
+		}
+	}
 
+	var merged []*types.Operation
+	emitted := make(map[*transferGroup]bool)
+	for _, op := range ops {
+		group, ok := groups[op.OperationIdentifier.Index]
+		if !ok {
+			return nil, unclearIntentErr(
+				"no matching operation found",
+				op.OperationIdentifier.Index,
+			)
 		}
-		tp := opCopy.(types.Operation)
-		tmpOP := &tp
+		if emitted[group] {
+			continue
+		}
+		emitted[group] = true
 
-		if tmpOP.Metadata == nil {
-			tmpOP.Metadata = make(map[string]interface{})
+		if len(group.receivers) == 0 {
+			tmpOP, terr := mergeOperations(group.sender, nil)
+			if terr != nil {
+				return nil, terr
+			}
+			merged = append(merged, tmpOP)
+			continue
+		}
+		for _, receiver := range group.receivers {
+			tmpOP, terr := mergeOperations(group.sender, receiver)
+			if terr != nil {
+				return nil, terr
+			}
+			merged = append(merged, tmpOP)
 		}
-		if matched != nil {
-			fromOp := tmpOP
-			fromAdd := fromOp.Account.Address
-			toOp := matched
-			toAdd := toOp.Account.Address
-			tmpOP.Account = fromOp.Account
-			tmpOP.Metadata["source"] = fromAdd
-			tmpOP.Metadata["destination"] = toAdd
-			tmpOP.Amount = toOp.Amount
+	}
+	return merged, nil
+}
 
-			matchedOperationHashMap[fromOp.OperationIdentifier.Index] = true
-			matchedOperationHashMap[toOp.OperationIdentifier.Index] = true
+// findBatchReceivers returns the unmatched receivers of sender, in
+// request order, whose amounts sum to the sender amount. It returns nil
+// if there are none.
+func findBatchReceivers(
+	ops []*types.Operation,
+	sender *types.Operation,
+	matchedOperationHashMap map[int64]bool,
+) []*types.Operation {
+	want := solanago.ValueToBaseAmount(sender.Amount.Value)
+	var total uint64
+	var receivers []*types.Operation
+	for _, v := range ops {
+		if _, ok := matchedOperationHashMap[v.OperationIdentifier.Index]; ok {
+			continue
+		}
+		if v.Type != sender.Type || v.Amount == nil || isNegativeAmount(v.Amount) ||
+			v.Amount.Currency.Symbol != sender.Amount.Currency.Symbol {
+			continue
+		}
+		total += solanago.ValueToBaseAmount(v.Amount.Value)
+		receivers = append(receivers, v)
+		if total >= want {
+			break
+		}
+	}
+	if total != want || len(receivers) == 0 {
+		return nil
+	}
+	return receivers
+}
+
+// mergeOperations deep copies fromOp and, if matched is not nil, merges
+// the receiver operation matched into it.
+func mergeOperations(fromOp *types.Operation, matched *types.Operation) (*types.Operation, *types.Error) {
+	opCopy, err := copystructure.Copy(*fromOp)
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("Cannot deep copy operations"))
+	}
+	tp := opCopy.(types.Operation)
+	tmpOP := &tp
+
+	if tmpOP.Metadata == nil {
+		tmpOP.Metadata = make(map[string]interface{})
+	}
+	if matched != nil {
+		tmpOP.OperationIdentifier = &types.OperationIdentifier{
+			Index: matched.OperationIdentifier.Index,
+		}
+		tmpOP.Metadata["source"] = fromOp.Account.Address
+		tmpOP.Metadata["destination"] = matched.Account.Address
+		tmpOP.Amount = matched.Amount
+		if _, ok := tmpOP.Metadata[solanago.MemoKey]; !ok && matched.Metadata[solanago.MemoKey] != nil {
+			tmpOP.Metadata[solanago.MemoKey] = matched.Metadata[solanago.MemoKey]
+		}
+	}
+	return tmpOP, nil
+}
+
+// getRelatedGroups validates the operations linked through
+// RelatedOperations and returns their transfer groups keyed by the
+// index of every grouped operation. Only batch transfers may fund
+// more than one receiver.
+func getRelatedGroups(ops []*types.Operation) (map[int64]*transferGroup, *types.Error) {
+	byIndex := make(map[int64]*types.Operation)
+	for _, op := range ops {
+		byIndex[op.OperationIdentifier.Index] = op
+	}
+
+	senderOf := make(map[int64]*types.Operation)
+	groups := make(map[int64]*transferGroup)
+	for _, op := range ops {
+		index := op.OperationIdentifier.Index
+		for _, rel := range op.RelatedOperations {
+			other, ok := byIndex[rel.Index]
+			if !ok || rel.Index == index {
+				return nil, unclearIntentErr("related operation not found", index, rel.Index)
+			}
+			if reason := validatePair(op, other); reason != "" {
+				return nil, unclearIntentErr(reason, index, rel.Index)
+			}
+			sender, receiver := op, other
+			if !isNegativeAmount(op.Amount) {
+				sender, receiver = other, op
+			}
+			senderIndex := sender.OperationIdentifier.Index
+			receiverIndex := receiver.OperationIdentifier.Index
 
-		} else {
-			matchedOperationHashMap[op.OperationIdentifier.Index] = true
+			if s, ok := senderOf[receiverIndex]; ok {
+				if s.OperationIdentifier.Index == senderIndex {
+					continue
+				}
+				return nil, unclearIntentErr(
+					"receiver is related to more than one sender",
+					receiverIndex, s.OperationIdentifier.Index, senderIndex,
+				)
+			}
+			group, ok := groups[senderIndex]
+			if !ok {
+				group = &transferGroup{sender: sender}
+				groups[senderIndex] = group
+			}
+			if len(group.receivers) > 0 && !isBatchTransfer(sender.Type) {
+				return nil, unclearIntentErr(
+					"operation is related to more than one operation",
+					senderIndex, group.receivers[0].OperationIdentifier.Index, receiverIndex,
+				)
+			}
+			group.receivers = append(group.receivers, receiver)
+			groups[receiverIndex] = group
+			senderOf[receiverIndex] = sender
 		}
+	}
+
+	for index, group := range groups {
+		if index != group.sender.OperationIdentifier.Index {
+			continue
+		}
+		var total uint64
+		indices := []int64{index}
+		for _, receiver := range group.receivers {
+			total += solanago.ValueToBaseAmount(receiver.Amount.Value)
+			indices = append(indices, receiver.OperationIdentifier.Index)
+		}
+		if total != solanago.ValueToBaseAmount(group.sender.Amount.Value) {
+			return nil, unclearIntentErr(
+				"receiver amounts do not sum to the sender amount",
+				indices...,
+			)
+		}
+	}
+	return groups, nil
+}
+
+// validatePair returns why a and b cannot be a sender and receiver pair
+// or an empty string if they can.
+func validatePair(a *types.Operation, b *types.Operation) string {
+	if a.Type != b.Type {
+		return "related operations have different types"
+	}
+	if a.Account == nil || b.Account == nil {
+		return "related operations must have an account"
+	}
+	if a.Amount == nil || b.Amount == nil {
+		return "related operations must have an amount"
+	}
+	if a.Amount.Currency == nil || b.Amount.Currency == nil ||
+		a.Amount.Currency.Symbol != b.Amount.Currency.Symbol {
+		return "related operations have different currencies"
+	}
+	if isNegativeAmount(a.Amount) == isNegativeAmount(b.Amount) {
+		return "related operations must have a sender and a receiver"
+	}
+	return ""
+}
+
+// isBatchTransfer returns true for the transfer types one sender
+// operation can use to fund several receivers.
+func isBatchTransfer(opType string) bool {
+	switch opType {
+	case solanago.System__Transfer,
+		solanago.SplToken__Transfer,
+		solanago.SplToken__TransferChecked,
+		solanago.SplToken__TransferNew,
+		solanago.SplToken__TransferWithSystem:
+		return true
+	}
+	return false
+}
+
+func isNegativeAmount(amount *types.Amount) bool {
+	return amount != nil && strings.Contains(amount.Value, "-")
+}
+
+// operationsToInstructions converts merged operations into instructions.
+func operationsToInstructions(
+	merged []*types.Operation,
+	splTokenAccMap map[string]solanago.SplAccounts,
+) ([]solPTypes.Instruction, *types.Error) {
+	var instructions []solPTypes.Instruction
+	for _, tmpOP := range merged {
+		var ins []solPTypes.Instruction
 		switch strings.Split(tmpOP.Type, solanago.Separator)[0] {
 		case "System":
 			s := operations.SystemOperationMetadata{}
 			s.SetMeta(tmpOP)
-			instructions = append(instructions, (s.ToInstructions(tmpOP.Type))...)
+			if len(s.Owner) > 0 && !solanago.IsValidAddress(s.Owner) {
+				return nil, wrapErrDetails(ErrInvalidAddress, map[string]interface{}{
+					"owner":   s.Owner,
+					"indices": []int64{tmpOP.OperationIdentifier.Index},
+				})
+			}
+			if err := s.VerifyDestination(tmpOP.Type); err != nil {
+				return nil, wrapErrDetails(ErrInvalidAddress, map[string]interface{}{
+					"context": err.Error(),
+					"indices": []int64{tmpOP.OperationIdentifier.Index},
+				})
+			}
+			if err := s.VerifySeed(tmpOP.Type); err != nil {
+				return nil, wrapErrDetails(ErrUnclearIntent, map[string]interface{}{
+					"context": err.Error(),
+					"indices": []int64{tmpOP.OperationIdentifier.Index},
+				})
+			}
+			ins = s.ToInstructions(tmpOP.Type)
 
 			break
 		case "SplToken":
 			s := operations.SplTokenOperationMetadata{}
-			s.SetMeta(tmpOP, meta.SplTokenAccMapKey)
-			instructions = append(instructions, (s.ToInstructions(tmpOP.Type))...)
+			s.SetMeta(tmpOP, splTokenAccMap)
+			ins = s.ToInstructions(tmpOP.Type)
 			break
 		case "SplAssociatedTokenAccount":
 			s := operations.SplAssociatedTokenAccountOperationMetadata{}
 			s.SetMeta(tmpOP)
-			instructions = append(instructions, (s.ToInstructions(tmpOP.Type))...)
+			ins = s.ToInstructions(tmpOP.Type)
 			break
 		default:
 			return nil, wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("Operation not implemented for construction"))
 		}
+		if len(ins) == 0 {
+			return nil, unclearIntentErr(
+				"operation has no instructions",
+				tmpOP.OperationIdentifier.Index,
+			)
+		}
+		instructions = append(instructions, ins...)
 	}
-	signers := solPTypes.GetUniqueSigners(instructions)
-	feePayer := common.PublicKeyFromString(signers[0])
-	blockHash := meta.BlockHash
-	var message solPTypes.Message
+	if len(instructions) == 0 {
+		return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("no instructions to construct"))
+	}
+	return instructions, nil
+}
 
+// ConstructionPayloads implements the /construction/payloads endpoint.
+func (s *ConstructionAPIService) ConstructionPayloads(
+	ctx context.Context,
+	request *types.ConstructionPayloadsRequest,
+) (*types.ConstructionPayloadsResponse, *types.Error) {
+	// Convert map to Metadata struct
+	var meta ConstructionMetadata
+
+	if err := unmarshalJSONMap(request.Metadata, &meta); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	merged, terr := matchOperations(request.Operations)
+	if terr != nil {
+		return nil, terr
+	}
+	if terr := applySweeps(merged, meta.Sweeps); terr != nil {
+		return nil, terr
+	}
 	withNonce, hasNonce := solanago.GetWithNonce(request.Metadata)
+	var nonce *solanago.WithNonce
 	if hasNonce {
-		message = ss.NewMessageWithNonce(feePayer, instructions, common.PublicKeyFromString(withNonce.Account), common.PublicKeyFromString(withNonce.Authority))
-	} else {
-		message = solPTypes.NewMessage(feePayer, instructions, blockHash)
+		nonce = &withNonce
 	}
-	//TODO: use suggestedFee somewhere
-
-	//unsigned signature
-	var sig []solPTypes.Signature
-	x := make([]byte, 64)
-	for i := 0; i < int(message.Header.NumRequireSignatures); i++ {
-		sig = append(sig, x)
+	blockHash := meta.BlockHash
+	if len(meta.FeePayer) > 0 && !solanago.IsValidAddress(meta.FeePayer) {
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("invalid fee payer %s", meta.FeePayer))
 	}
-	tx := solPTypes.Transaction{
-		Signatures: sig,
-		Message:    message,
+	encoding := meta.Encoding
+	if len(encoding) == 0 {
+		encoding = s.config.Encoding
 	}
-	tx.Message.RecentBlockHash = blockHash
-	msgBytes, _ := tx.Message.Serialize()
-	var signingPayloads []*types.SigningPayload
-	for _, sg := range signers {
-		signingPayloads = append(signingPayloads, &types.SigningPayload{
-			AccountIdentifier: &types.AccountIdentifier{
-				Address: sg,
-			},
-			Bytes:         msgBytes,
-			SignatureType: types.Ed25519,
-		})
+	if len(encoding) == 0 {
+		encoding = solanago.Base58Encoding
+	}
+	if !solanago.IsValidEncoding(encoding) {
+		return nil, wrapErr(ErrUnsupportedEncoding, fmt.Errorf("unsupported encoding %s", encoding))
 	}
 
-	txUnsigned, err := tx.Serialize()
+	batches := [][]*types.Operation{merged}
+	if meta.Split {
+		batches, terr = splitOperations(merged, meta.SplTokenAccMapKey, blockHash, nonce, meta.FeePayer)
+		if terr != nil {
+			return nil, terr
+		}
+	}
+	//TODO: use suggestedFee somewhere
 
-	if err != nil {
-		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	var txs []string
+	var signingPayloads []*types.SigningPayload
+	for _, batch := range batches {
+		instructions, terr := operationsToInstructions(batch, meta.SplTokenAccMapKey)
+		if terr != nil {
+			return nil, terr
+		}
+		tx, signers, terr := buildTransaction(instructions, blockHash, nonce, meta.FeePayer)
+		if terr != nil {
+			return nil, terr
+		}
+		txUnsigned, terr := serializeTransaction(tx)
+		if terr != nil {
+			return nil, terr
+		}
+
+		msgBytes, _ := tx.Message.Serialize()
+		for _, sg := range signers {
+			signingPayloads = append(signingPayloads, &types.SigningPayload{
+				AccountIdentifier: &types.AccountIdentifier{
+					Address: sg,
+				},
+				Bytes:         msgBytes,
+				SignatureType: types.Ed25519,
+			})
+		}
+		txs = append(txs, solanago.EncodeTx(txUnsigned, encoding))
 	}
 
 	return &types.ConstructionPayloadsResponse{
-		UnsignedTransaction: base58.Encode(txUnsigned),
+		UnsignedTransaction: strings.Join(txs, solanago.TxGroupSeparator),
 		Payloads:            signingPayloads,
 	}, nil
 }
 
-func GetSigningKeypairPositions(message solPTypes.Message, pubKeys []common.PublicKey) ([]uint, *types.Error) {
-	if len(message.Accounts) < int(message.Header.NumRequireSignatures) {
-		return nil, wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("invalid positions"))
-	}
-	signedKeys := message.Accounts[0:message.Header.NumRequireSignatures]
-	var positions []uint
-	for _, p := range pubKeys {
-		index := indexOf(p, signedKeys)
-		if index > -1 {
-			positions = append(positions, uint(index))
-		}
-	}
-	return positions, nil
-}
-func indexOf(element common.PublicKey, data []common.PublicKey) int {
-	for k, v := range data {
-		if element == v {
-			return k
-		}
-	}
-	return -1 //not found.
-}
-
 // ConstructionCombine implements the /construction/combine
 // endpoint.
 func (s *ConstructionAPIService) ConstructionCombine(
@@ -344,29 +817,134 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	request *types.ConstructionCombineRequest,
 ) (*types.ConstructionCombineResponse, *types.Error) {
 
-	tx, err := solanago.GetTxFromStr(request.UnsignedTransaction)
+	// The signed transaction keeps the encoding of the unsigned one.
+	txs, encoding, err := solanago.DecodeTxGroup(request.UnsignedTransaction)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
-	var pubKeys []common.PublicKey
-	for _, s := range request.Signatures {
-		pubKeys = append(pubKeys, common.PublicKeyFromBytes(s.PublicKey.Bytes))
+	var signedTxs []string
+	used := 0
+	for _, tx := range txs {
+		signatures := request.Signatures
+		if len(txs) > 1 {
+			signatures = signaturesForMessage(tx.Message, request.Signatures)
+		}
+		used += len(signatures)
+		signedTx, terr := combineTransaction(tx, signatures, encoding)
+		if terr != nil {
+			return nil, terr
+		}
+		signedTxs = append(signedTxs, signedTx)
 	}
-	positions, errr := GetSigningKeypairPositions(tx.Message, pubKeys)
-	if errr != nil {
-		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	if used < len(request.Signatures) {
+		// Some signatures sign none of the transactions of the group.
+		return nil, wrapErrDetails(ErrSignatureInvalid, map[string]interface{}{
+			"context":    "signing payload matches no transaction",
+			"signatures": len(request.Signatures) - used,
+		})
 	}
-	for i, p := range positions {
-		tx.Signatures[p] = request.Signatures[i].Bytes
+
+	return &types.ConstructionCombineResponse{
+		SignedTransaction: strings.Join(signedTxs, solanago.TxGroupSeparator),
+	}, nil
+}
+
+// combineTransaction verifies signatures against the message of tx,
+// writes them into tx and returns the signed transaction in encoding.
+//
+// Every signer of the message needs a signature, unless its slot is
+// already signed. A signature without bytes defers its signer: the slot
+// is left zeroed and the partially signed transaction can be passed to
+// /construction/combine again with the remaining signatures.
+func combineTransaction(tx solPTypes.Transaction, signatures []*types.Signature, encoding string) (string, *types.Error) {
+	message, err := tx.Message.Serialize()
+	if err != nil {
+		return "", wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	signers := messageSigners(tx.Message)
+	if len(tx.Signatures) != len(signers) {
+		return "", wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("invalid signature count"))
 	}
+
+	signed := make(map[int]bool)
+	for _, sig := range signatures {
+		if sig.PublicKey == nil || len(sig.PublicKey.Bytes) != common.PublicKeyLength {
+			return "", signatureErr("invalid public key", "")
+		}
+		signer := base58.Encode(sig.PublicKey.Bytes)
+		if len(sig.SignatureType) > 0 && sig.SignatureType != types.Ed25519 {
+			return "", signatureErr("unsupported signature type "+string(sig.SignatureType), signer)
+		}
+		position := -1
+		for i, v := range signers {
+			if v == signer {
+				position = i
+			}
+		}
+		if position < 0 {
+			return "", signatureErr("unknown signer", signer)
+		}
+		if signed[position] {
+			return "", signatureErr("duplicate signer", signer)
+		}
+		signed[position] = true
+		if len(sig.Bytes) == 0 {
+			continue
+		}
+		if !ed25519.Verify(sig.PublicKey.Bytes, message, sig.Bytes) {
+			return "", signatureErr("signature does not verify", signer)
+		}
+		tx.Signatures[position] = sig.Bytes
+	}
+
+	var missing []string
+	for i, v := range signers {
+		if signed[i] {
+			continue
+		}
+		if !solanago.HasSignature(tx.Signatures[i]) {
+			missing = append(missing, v)
+			continue
+		}
+		// Keep the signatures of an earlier partial combine.
+		if !ed25519.Verify(common.PublicKeyFromString(v).Bytes(), message, tx.Signatures[i]) {
+			return "", signatureErr("signature does not verify", v)
+		}
+	}
+	if len(missing) > 0 {
+		return "", wrapErrDetails(ErrSignatureInvalid, map[string]interface{}{
+			"context":         "missing signers",
+			"missing_signers": missing,
+		})
+	}
+
 	signedTx, err := tx.Serialize()
 	if err != nil {
-		return nil, wrapErr(ErrSignatureInvalid, err)
+		return "", wrapErr(ErrSignatureInvalid, err)
 	}
+	return solanago.EncodeTx(signedTx, encoding), nil
+}
 
-	return &types.ConstructionCombineResponse{
-		SignedTransaction: base58.Encode(signedTx),
-	}, nil
+// signatureErr returns ErrSignatureInvalid for the signature of signer.
+func signatureErr(reason string, signer string) *types.Error {
+	details := map[string]interface{}{"context": reason}
+	if len(signer) > 0 {
+		details["signer"] = signer
+	}
+	return wrapErrDetails(ErrSignatureInvalid, details)
+}
+
+// signaturesForMessage returns the signatures whose signing payload is
+// message. Transactions of a group are told apart by their payloads.
+func signaturesForMessage(message solPTypes.Message, signatures []*types.Signature) []*types.Signature {
+	msgBytes, _ := message.Serialize()
+	var matched []*types.Signature
+	for _, s := range signatures {
+		if s.SigningPayload != nil && bytes.Equal(s.SigningPayload.Bytes, msgBytes) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
 }
 
 // ConstructionHash implements the /construction/hash endpoint.
@@ -375,17 +953,44 @@ func (s *ConstructionAPIService) ConstructionHash(
 	request *types.ConstructionHashRequest,
 ) (*types.TransactionIdentifierResponse, *types.Error) {
 
-	tx, err := solanago.GetTxFromStr(request.SignedTransaction)
+	txs, err := solanago.GetTxsFromStr(request.SignedTransaction)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
-	hash := tx.Signatures[0].ToBase58()
+	var hashes []string
+	for _, tx := range txs {
+		hash, terr := transactionHash(tx)
+		if terr != nil {
+			return nil, terr
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return transactionIdentifierResponse(hashes), nil
+}
 
-	return &types.TransactionIdentifierResponse{
+// transactionHash returns the hash of tx, its first signature.
+func transactionHash(tx solPTypes.Transaction) (string, *types.Error) {
+	if len(tx.Signatures) == 0 {
+		return "", wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("transaction has no signatures"))
+	}
+	return tx.Signatures[0].ToBase58(), nil
+}
+
+// transactionIdentifierResponse identifies a transaction group by the
+// hash of its first transaction and lists every hash in the metadata.
+func transactionIdentifierResponse(hashes []string) *types.TransactionIdentifierResponse {
+	resp := &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: hash,
+			Hash: hashes[0],
 		},
-	}, nil
+	}
+	if len(hashes) > 1 {
+		resp.Metadata = map[string]interface{}{
+			solanago.TransactionHashesKey: hashes,
+		}
+	}
+	return resp
 }
 
 // ConstructionParse implements the /construction/parse endpoint.
@@ -394,32 +999,74 @@ func (s *ConstructionAPIService) ConstructionParse(
 	request *types.ConstructionParseRequest,
 ) (*types.ConstructionParseResponse, *types.Error) {
 
-	tx, err := solanago.GetTxFromStr(request.Transaction)
+	txs, err := solanago.GetTxsFromStr(request.Transaction)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
 	var signers []*types.AccountIdentifier
-	sgns := tx.Message.GetUniqueSigners()
-	for _, v := range sgns {
-		signers = append(signers, &types.AccountIdentifier{
-			Address: v,
-		})
-	}
-	parsedTx, err := solanago.ToParsedTransaction(tx)
-	if err != nil {
-		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
-	}
+	var operations []*types.Operation
+	var memos []string
+	seen := make(map[string]bool)
+	for _, tx := range txs {
+		for i, v := range messageSigners(tx.Message) {
+			// Only the signature slots of a signed transaction that
+			// hold a signature name a signer.
+			if !request.Signed || i >= len(tx.Signatures) || !solanago.HasSignature(tx.Signatures[i]) {
+				continue
+			}
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			signers = append(signers, &types.AccountIdentifier{
+				Address: v,
+			})
+		}
+		parsedTx, err := solanago.ToParsedTransaction(tx)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
 
-	operations := solanago.GetRosOperationsFromTx(parsedTx, "")
+		// Operations of later transactions in a group follow the
+		// operations of earlier ones.
+		offset := int64(len(operations))
+		ops, err := constructionOperations(tx, parsedTx)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+		for _, op := range ops {
+			op.OperationIdentifier.Index += offset
+			for _, rel := range op.RelatedOperations {
+				rel.Index += offset
+			}
+			operations = append(operations, op)
+		}
+		memos = append(memos, solanago.GetMemosFromTx(parsedTx)...)
+	}
 
 	resp := &types.ConstructionParseResponse{
 		Operations:               operations,
 		AccountIdentifierSigners: signers,
 	}
+	if len(memos) > 0 {
+		resp.Metadata = map[string]interface{}{solanago.MemosKey: memos}
+	}
 	return resp, nil
 }
 
+// preflightCommitment returns the commitment the preflight checks of a
+// submitted transaction run at: the read commitment, but at most
+// confirmed as a blockhash read with a confirmed per-request override
+// is not yet known at finalized.
+func (s *ConstructionAPIService) preflightCommitment(ctx context.Context) string {
+	commitment := s.client.Commitment(ctx)
+	if commitment == solanago.ProcessedCommitment {
+		return commitment
+	}
+	return solanago.ConfirmedCommitment
+}
+
 // ConstructionSubmit implements the /construction/submit endpoint.
 func (s *ConstructionAPIService) ConstructionSubmit(
 	ctx context.Context,
@@ -428,19 +1075,68 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 	if s.config.Mode != configuration.Online {
 		return nil, ErrUnavailableOffline
 	}
-	hash, err := s.client.Rpc.SendTransaction(ctx, request.SignedTransaction, ss.SendTransactionConfig{
-		SkipPreflight:       false,
-		PreflightCommitment: "max",
-		Encoding:            "base58",
-	})
+	txs, err := solanago.GetTxsFromStr(request.SignedTransaction)
 	if err != nil {
-		return nil, wrapErr(ErrBroadcastFailed, err)
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	// Every transaction of the group is checked before any is sent.
+	signatures := make([]string, len(txs))
+	for i, tx := range txs {
+		signature, terr := transactionHash(tx)
+		if terr != nil {
+			return nil, terr
+		}
+		signatures[i] = signature
+	}
+	var hashes []string
+	var statuses []string
+	for i, tx := range txs {
+		// A transaction submitted before is not sent again, unless
+		// it is pending and due a rebroadcast.
+		if status, ok := s.submitter.resubmit(ctx, signatures[i]); ok {
+			hashes = append(hashes, status.Hash)
+			statuses = append(statuses, status.Status)
+			continue
+		}
+		account, _ := nonceAccount(tx)
+		rawTx, err := tx.Serialize()
+		if err != nil {
+			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		}
+		commitment := s.preflightCommitment(ctx)
+		hash, err := s.client.SendTransaction(ctx, rawTx, false, commitment)
+		if solanago.IsAlreadyProcessed(err) {
+			hash, err = signatures[i], nil
+		}
+		if len(account) > 0 {
+			if err != nil {
+				s.noncePool.release(account)
+			} else {
+				s.noncePool.submitted(account, hash)
+			}
+		}
+		if err != nil {
+			terr := nodeErr(ErrBroadcastFailed, err)
+			if len(hashes) > 0 {
+				terr.Details[solanago.TransactionHashesKey] = hashes
+			}
+			return nil, terr
+		}
+		// The submitter rebroadcasts the transaction until it is
+		// confirmed or expires.
+		s.submitter.track(hash, tx, rawTx, commitment)
+		logger.FromContext(ctx).Info("transaction submitted", "signature", hash)
+		hashes = append(hashes, hash)
+		statuses = append(statuses, solanago.TransactionPending)
 	}
 
-	txIdentifier := &types.TransactionIdentifier{
-		Hash: hash,
+	resp := transactionIdentifierResponse(hashes)
+	if resp.Metadata == nil {
+		resp.Metadata = map[string]interface{}{}
 	}
-	return &types.TransactionIdentifierResponse{
-		TransactionIdentifier: txIdentifier,
-	}, nil
+	resp.Metadata[solanago.StatusKey] = statuses[0]
+	if len(statuses) > 1 {
+		resp.Metadata[solanago.TransactionStatusesKey] = statuses
+	}
+	return resp, nil
 }
@@ -1,17 +1,27 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"crypto/ed25519"
 
+	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/imerkle/rosetta-solana-go/configuration"
+	"github.com/imerkle/rosetta-solana-go/logger"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/portto/solana-go-sdk/common"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gotest.tools/assert"
 )
 
@@ -185,12 +195,18 @@ func constructionPipe(t *testing.T, ops []*types.Operation, submit bool) {
 		Operations:        ops,
 		Metadata:          map[string]interface{}{},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	var optsjson map[string]interface{}
 	unmarshalJSONMap(preRes.Options, &optsjson)
 	metaRes, err := constructionAPIService.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
 		NetworkIdentifier: cfg.Network,
 		Options:           optsjson,
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	payRes, err := constructionAPIService.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
 		NetworkIdentifier: cfg.Network,
 		Operations:        ops,
@@ -275,3 +291,1832 @@ func constructionPipe(t *testing.T, ops []*types.Operation, submit bool) {
 		fmt.Println(submitRes.TransactionIdentifier.Hash)
 	}
 }
+
+func TestConstructionPayloadsFeePayer(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+
+	from := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	treasury := solPTypes.NewAccount().PublicKey.ToBase58()
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-10", Currency: cSol},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "10", Currency: cSol},
+		},
+	}
+
+	_, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{solanago.FeePayerKey: "invalid"},
+	})
+	assert.Equal(t, ErrInvalidAddress.Code, terr.Code)
+
+	preRes, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{solanago.FeePayerKey: treasury},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, treasury, preRes.Options[solanago.FeePayerKey])
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata: map[string]interface{}{
+			"blockhash":          "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+			solanago.FeePayerKey: treasury,
+		},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 2, len(payRes.Payloads))
+	assert.Equal(t, treasury, payRes.Payloads[0].AccountIdentifier.Address)
+	assert.Equal(t, from, payRes.Payloads[1].AccountIdentifier.Address)
+
+	tx, err := solanago.GetTxFromStr(payRes.UnsignedTransaction)
+	assert.NilError(t, err)
+	assert.Equal(t, treasury, tx.Message.Accounts[0].ToBase58())
+
+	parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	// An unsigned transaction has no signers yet.
+	assert.Equal(t, 0, len(parseRes.AccountIdentifierSigners))
+}
+
+// transfer returns the System__Transfer operation index moving value
+// lamports from or to address, related to the operations of related.
+func transfer(index int64, address string, value string, related ...int64) *types.Operation {
+	op := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: index},
+		Type:                solanago.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: address},
+		Amount: &types.Amount{
+			Value:    value,
+			Currency: &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals},
+		},
+	}
+	for _, r := range related {
+		op.RelatedOperations = append(op.RelatedOperations, &types.OperationIdentifier{Index: r})
+	}
+	return op
+}
+
+func TestMatchOperationsRelated(t *testing.T) {
+	// Two identical transfers are paired as declared, not by position.
+	ops := []*types.Operation{
+		transfer(0, "A", "-10"),
+		transfer(1, "B", "-10"),
+		transfer(2, "C", "10", 1),
+		transfer(3, "D", "10", 0),
+	}
+	merged, terr := matchOperations(ops)
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 2, len(merged))
+	assert.Equal(t, "A", merged[0].Account.Address)
+	assert.Equal(t, "D", merged[0].Metadata["destination"])
+	assert.Equal(t, "B", merged[1].Account.Address)
+	assert.Equal(t, "C", merged[1].Metadata["destination"])
+
+	// Explicit pairs and the amount heuristic can be mixed.
+	ops = []*types.Operation{
+		transfer(0, "A", "10", 1),
+		transfer(1, "B", "-10"),
+		transfer(2, "C", "-5"),
+		transfer(3, "D", "5"),
+	}
+	merged, terr = matchOperations(ops)
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, "B", merged[0].Account.Address)
+	assert.Equal(t, "A", merged[0].Metadata["destination"])
+	assert.Equal(t, "C", merged[1].Account.Address)
+	assert.Equal(t, "D", merged[1].Metadata["destination"])
+
+	_, terr = matchOperations([]*types.Operation{
+		transfer(0, "A", "-10"),
+		transfer(1, "B", "9", 0),
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+	assert.DeepEqual(t, []int64{0, 1}, terr.Details["indices"])
+
+	_, terr = matchOperations([]*types.Operation{
+		transfer(0, "A", "-10"),
+		transfer(1, "B", "10", 0),
+		transfer(2, "C", "10", 0),
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+	assert.DeepEqual(t, []int64{0, 1, 2}, terr.Details["indices"])
+
+	_, terr = matchOperations([]*types.Operation{
+		transfer(0, "A", "-10"),
+		transfer(1, "B", "10", 0),
+		transfer(2, "C", "10", 1),
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+	assert.Equal(t, "related operations must have a sender and a receiver", terr.Details["context"])
+}
+
+func TestMatchOperationsBatch(t *testing.T) {
+	// One sender funds several receivers declared through related operations.
+	merged, terr := matchOperations([]*types.Operation{
+		transfer(0, "A", "-30"),
+		transfer(1, "B", "10", 0),
+		transfer(2, "C", "15", 0),
+		transfer(3, "D", "5", 0),
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 3, len(merged))
+	for i, dest := range []string{"B", "C", "D"} {
+		assert.Equal(t, "A", merged[i].Account.Address)
+		assert.Equal(t, dest, merged[i].Metadata["destination"])
+		assert.Equal(t, int64(i+1), merged[i].OperationIdentifier.Index)
+	}
+	assert.Equal(t, "15", merged[1].Amount.Value)
+
+	// Without related operations receivers are grouped in request order.
+	merged, terr = matchOperations([]*types.Operation{
+		transfer(0, "A", "-30"),
+		transfer(1, "B", "20"),
+		transfer(2, "C", "10"),
+		transfer(3, "D", "-7"),
+		transfer(4, "E", "7"),
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 3, len(merged))
+	assert.Equal(t, "B", merged[0].Metadata["destination"])
+	assert.Equal(t, "C", merged[1].Metadata["destination"])
+	assert.Equal(t, "D", merged[2].Account.Address)
+	assert.Equal(t, "E", merged[2].Metadata["destination"])
+
+	_, terr = matchOperations([]*types.Operation{
+		transfer(0, "A", "-30"),
+		transfer(1, "B", "20"),
+		transfer(2, "C", "20"),
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+	assert.DeepEqual(t, []int64{0}, terr.Details["indices"])
+}
+
+func TestConstructionPayloadsBatch(t *testing.T) {
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	cfg := &configuration.Configuration{Mode: configuration.Offline}
+	s := NewConstructionAPIService(cfg, nil)
+	batch := func(receivers int) []*types.Operation {
+		ops := []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: solPTypes.NewAccount().PublicKey.ToBase58()},
+			Amount:              &types.Amount{Value: fmt.Sprint(-10 * receivers), Currency: cSol},
+		}}
+		for i := 1; i <= receivers; i++ {
+			ops = append(ops, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+				Type:                solanago.System__Transfer,
+				Account:             &types.AccountIdentifier{Address: solPTypes.NewAccount().PublicKey.ToBase58()},
+				Amount:              &types.Amount{Value: "10", Currency: cSol},
+			})
+		}
+		return ops
+	}
+	meta := map[string]interface{}{
+		"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+	}
+
+	ops := batch(3)
+	payRes, terr := s.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata:   meta,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 1, len(payRes.Payloads))
+	parseRes, terr := s.ConstructionParse(context.Background(), &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 6, len(parseRes.Operations))
+
+	_, terr = s.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
+		Operations: batch(40),
+		Metadata:   meta,
+	})
+	assert.Equal(t, ErrTransactionTooLarge.Code, terr.Code)
+	assert.Equal(t, solanago.MaxTransactionSize, terr.Details["max_size"])
+}
+
+func TestConstructionPayloadsSplit(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	cfg := &configuration.Configuration{Mode: configuration.Offline}
+	s := NewConstructionAPIService(cfg, nil)
+
+	sender := solPTypes.NewAccount()
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                solanago.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: sender.PublicKey.ToBase58()},
+		Amount:              &types.Amount{Value: "-400", Currency: cSol},
+	}}
+	for i := 1; i <= 40; i++ {
+		ops = append(ops, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: solPTypes.NewAccount().PublicKey.ToBase58()},
+			Amount:              &types.Amount{Value: "10", Currency: cSol},
+		})
+	}
+
+	_, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{Operations: ops})
+	assert.Equal(t, ErrTransactionTooLarge.Code, terr.Code)
+
+	preRes, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{solanago.SplitKey: true},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, true, preRes.Options[solanago.SplitKey])
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata: map[string]interface{}{
+			"blockhash":       "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+			solanago.SplitKey: true,
+		},
+	})
+	assert.Assert(t, terr == nil)
+	txs := solanago.SplitTxGroup(payRes.UnsignedTransaction)
+	assert.Assert(t, len(txs) > 1)
+	assert.Equal(t, len(txs), len(payRes.Payloads))
+
+	parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 80, len(parseRes.Operations))
+	assert.Equal(t, int64(79), parseRes.Operations[79].OperationIdentifier.Index)
+	assert.Equal(t, 0, len(parseRes.AccountIdentifierSigners))
+
+	var sigs []*types.Signature
+	for _, v := range payRes.Payloads {
+		sigs = append(sigs, &types.Signature{
+			SigningPayload: v,
+			PublicKey: &types.PublicKey{
+				Bytes:     sender.PublicKey.Bytes(),
+				CurveType: types.Edwards25519,
+			},
+			SignatureType: v.SignatureType,
+			Bytes:         ed25519.Sign(sender.PrivateKey, v.Bytes),
+		})
+	}
+	combRes, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		UnsignedTransaction: payRes.UnsignedTransaction,
+		Signatures:          sigs,
+	})
+	assert.Assert(t, terr == nil)
+
+	parseRes, terr = s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Signed:      true,
+		Transaction: combRes.SignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.DeepEqual(t, []*types.AccountIdentifier{{Address: sender.PublicKey.ToBase58()}}, parseRes.AccountIdentifierSigners)
+
+	hashRes, terr := s.ConstructionHash(ctx, &types.ConstructionHashRequest{
+		SignedTransaction: combRes.SignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	hashes := hashRes.Metadata[solanago.TransactionHashesKey].([]string)
+	assert.Equal(t, len(txs), len(hashes))
+	assert.Equal(t, hashes[0], hashRes.TransactionIdentifier.Hash)
+	for i, v := range solanago.SplitTxGroup(combRes.SignedTransaction) {
+		tx, err := solanago.GetTxFromStr(v)
+		assert.NilError(t, err)
+		assert.Assert(t, solanago.IsSigned(tx))
+		assert.Equal(t, hashes[i], tx.Signatures[0].ToBase58())
+	}
+}
+
+func TestConstructionPayloadsMultisig(t *testing.T) {
+	ctx := context.Background()
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	c := &types.Currency{Symbol: mint, Decimals: 2}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	meta := map[string]interface{}{
+		"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+	}
+
+	multisig := solPTypes.NewAccount().PublicKey.ToBase58()
+	signers := []string{
+		solPTypes.NewAccount().PublicKey.ToBase58(),
+		solPTypes.NewAccount().PublicKey.ToBase58(),
+	}
+	from := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	m := map[string]interface{}{
+		"authority": multisig,
+		"signers":   signers,
+	}
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.SplToken__Transfer,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1", Currency: c},
+			Metadata:            m,
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.SplToken__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1", Currency: c},
+			Metadata:            m,
+		},
+	}
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata:   meta,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 2, len(payRes.Payloads))
+	for _, v := range payRes.Payloads {
+		assert.Assert(t, solanago.Contains(signers, v.AccountIdentifier.Address))
+	}
+	parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, multisig, parseRes.Operations[0].Metadata["authority"])
+	assert.DeepEqual(t, []interface{}{signers[0], signers[1]}, parseRes.Operations[0].Metadata["signers"])
+
+	meta[solanago.FeePayerKey] = signers[0]
+	payRes, terr = s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.SplToken__InitializeMultisig,
+			Account:             &types.AccountIdentifier{Address: multisig},
+			Metadata: map[string]interface{}{
+				"signers": signers,
+				"m":       2,
+			},
+		}},
+		Metadata: meta,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 1, len(payRes.Payloads))
+	parseRes, terr = s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, solanago.SplToken__InitializeMultisig, parseRes.Operations[0].Type)
+	assert.Equal(t, multisig, parseRes.Operations[0].Account.Address)
+	assert.Equal(t, json.Number("2"), parseRes.Operations[0].Metadata["m"])
+	assert.DeepEqual(t, []interface{}{signers[0], signers[1]}, parseRes.Operations[0].Metadata["signers"])
+}
+
+func TestConstructionTokenAuthorityOps(t *testing.T) {
+	ctx := context.Background()
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	newAddress := func() string { return solPTypes.NewAccount().PublicKey.ToBase58() }
+
+	mint := newAddress()
+	account := newAddress()
+	authority := newAddress()
+	newAuthority := newAddress()
+	c := &types.Currency{Symbol: mint, Decimals: 2}
+
+	tests := []struct {
+		opType   string
+		account  string
+		amount   *types.Amount
+		metadata map[string]interface{}
+		parsed   map[string]interface{}
+	}{
+		{
+			opType:   solanago.SplToken__FreezeAccount,
+			account:  account,
+			metadata: map[string]interface{}{"mint": mint, "authority": authority},
+			parsed:   map[string]interface{}{"mint": mint, "authority": authority},
+		},
+		{
+			opType:   solanago.SplToken__ThawAccount,
+			account:  account,
+			metadata: map[string]interface{}{"mint": mint, "authority": authority},
+			parsed:   map[string]interface{}{"mint": mint, "authority": authority},
+		},
+		{
+			opType:  solanago.SplToken__SetAuthority,
+			account: mint,
+			metadata: map[string]interface{}{
+				"authority":      authority,
+				"authority_type": "mintTokens",
+				"new_authority":  newAuthority,
+			},
+			parsed: map[string]interface{}{
+				"authority":      authority,
+				"authority_type": "mintTokens",
+				"new_authority":  newAuthority,
+			},
+		},
+		{
+			opType:  solanago.SplToken__SetAuthority,
+			account: account,
+			metadata: map[string]interface{}{
+				"authority":      authority,
+				"authority_type": "closeAccount",
+			},
+			parsed: map[string]interface{}{
+				"authority":      authority,
+				"authority_type": "closeAccount",
+				"new_authority":  nil,
+			},
+		},
+		{
+			opType:   solanago.SplToken__SyncNative,
+			account:  account,
+			metadata: map[string]interface{}{},
+			parsed:   map[string]interface{}{},
+		},
+		{
+			opType:   solanago.SplToken__MintTo,
+			account:  account,
+			amount:   &types.Amount{Value: "150", Currency: c},
+			metadata: map[string]interface{}{"authority": authority},
+			parsed: map[string]interface{}{
+				"authority": authority,
+				"mint":      mint,
+				"amount":    json.Number("150"),
+			},
+		},
+		{
+			opType:   solanago.SplToken__MintToChecked,
+			account:  account,
+			amount:   &types.Amount{Value: "150", Currency: c},
+			metadata: map[string]interface{}{"authority": authority},
+			parsed:   map[string]interface{}{"authority": authority},
+		},
+		{
+			opType:   solanago.SplToken__Burn,
+			account:  account,
+			amount:   &types.Amount{Value: "-150", Currency: c},
+			metadata: map[string]interface{}{"authority": authority},
+			parsed: map[string]interface{}{
+				"authority": authority,
+				"mint":      mint,
+				"amount":    json.Number("150"),
+			},
+		},
+		{
+			opType:   solanago.SplToken__BurnChecked,
+			account:  account,
+			amount:   &types.Amount{Value: "-150", Currency: c},
+			metadata: map[string]interface{}{"authority": authority},
+			parsed:   map[string]interface{}{"authority": authority},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Assert(t, solanago.Contains(solanago.OperationTypes, test.opType))
+		payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Operations: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                test.opType,
+				Account:             &types.AccountIdentifier{Address: test.account},
+				Amount:              test.amount,
+				Metadata:            test.metadata,
+			}},
+			Metadata: map[string]interface{}{
+				"blockhash":          "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				solanago.FeePayerKey: authority,
+			},
+		})
+		assert.Assert(t, terr == nil, test.opType)
+		assert.Equal(t, 1, len(payRes.Payloads), test.opType)
+
+		parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			Transaction: payRes.UnsignedTransaction,
+		})
+		assert.Assert(t, terr == nil, test.opType)
+		assert.Equal(t, 1, len(parseRes.Operations), test.opType)
+		op := parseRes.Operations[0]
+		assert.Equal(t, test.opType, op.Type)
+		assert.Equal(t, test.account, op.Account.Address, test.opType)
+		for k, v := range test.parsed {
+			assert.DeepEqual(t, v, op.Metadata[k])
+		}
+		// The unchecked instructions do not record the decimals of
+		// their mint, so their amount is parsed into the metadata.
+		if _, ok := test.parsed["amount"]; ok {
+			assert.Assert(t, op.Amount == nil, test.opType)
+		} else if test.amount != nil {
+			assert.Equal(t, test.amount.Value, op.Amount.Value, test.opType)
+			assert.Equal(t, mint, op.Amount.Currency.Symbol, test.opType)
+		}
+		if test.opType == solanago.SplToken__MintToChecked || test.opType == solanago.SplToken__BurnChecked {
+			assert.Equal(t, int32(2), op.Amount.Currency.Decimals, test.opType)
+		}
+	}
+
+	_, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.SplToken__SetAuthority,
+			Account:             &types.AccountIdentifier{Address: mint},
+			Metadata:            map[string]interface{}{"authority_type": "unknown"},
+		}},
+		Metadata: map[string]interface{}{
+			"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+		},
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+}
+
+func TestConstructionWrapSol(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	owner := solPTypes.NewAccount().PublicKey.ToBase58()
+	meta := map[string]interface{}{
+		"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+	}
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.SplToken__WrapSol,
+			Account:             &types.AccountIdentifier{Address: owner},
+			Amount:              &types.Amount{Value: "-5000", Currency: cSol},
+		}},
+		Metadata: meta,
+	})
+	assert.Assert(t, terr == nil)
+	parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 1, len(parseRes.Operations))
+	op := parseRes.Operations[0]
+	assert.Equal(t, solanago.SplToken__WrapSol, op.Type)
+	assert.Equal(t, owner, op.Account.Address)
+	assert.Equal(t, "-5000", op.Amount.Value)
+	assert.Assert(t, op.Metadata == nil)
+
+	payRes, terr = s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.SplToken__UnwrapSol,
+			Account:             &types.AccountIdentifier{Address: owner},
+		}},
+		Metadata: meta,
+	})
+	assert.Assert(t, terr == nil)
+	parseRes, terr = s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 1, len(parseRes.Operations))
+	assert.Equal(t, solanago.SplToken__UnwrapSol, parseRes.Operations[0].Type)
+	assert.Equal(t, owner, parseRes.Operations[0].Account.Address)
+	// The owner's associated account is the default source_token.
+	assert.Assert(t, parseRes.Operations[0].Metadata == nil)
+}
+
+func TestConstructionSeedOps(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	base := solPTypes.NewAccount().PublicKey
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	derived := common.CreateWithSeed(base, "vault", common.SystemProgramID).ToBase58()
+
+	deriveRes, terr := s.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		PublicKey: &types.PublicKey{Bytes: base.Bytes(), CurveType: types.Edwards25519},
+		Metadata:  map[string]interface{}{"seed": "vault"},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, derived, deriveRes.AccountIdentifier.Address)
+	assert.Equal(t, common.SystemProgramID.ToBase58(), deriveRes.Metadata["owner"])
+
+	_, terr = s.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		PublicKey: &types.PublicKey{Bytes: base.Bytes(), CurveType: types.Edwards25519},
+		Metadata:  map[string]interface{}{"seed": "a seed longer than thirty-two bytes"},
+	})
+	assert.Equal(t, ErrInvalidSeed.Code, terr.Code)
+
+	seedMeta := map[string]interface{}{"base": base.ToBase58(), "seed": "vault"}
+	tests := []struct {
+		opType string
+		ops    []*types.Operation
+	}{
+		{
+			opType: solanago.System__CreateAccountWithSeed,
+			ops: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                solanago.System__CreateAccountWithSeed,
+				Account:             &types.AccountIdentifier{Address: base.ToBase58()},
+				Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+				Metadata:            map[string]interface{}{"seed": "vault", "space": 10},
+			}, {
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				Type:                solanago.System__CreateAccountWithSeed,
+				Account:             &types.AccountIdentifier{Address: derived},
+				Amount:              &types.Amount{Value: "1000", Currency: cSol},
+			}},
+		},
+		{
+			opType: solanago.System__TransferWithSeed,
+			ops: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                solanago.System__TransferWithSeed,
+				Account:             &types.AccountIdentifier{Address: derived},
+				Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+				Metadata:            seedMeta,
+			}, {
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				Type:                solanago.System__TransferWithSeed,
+				Account:             &types.AccountIdentifier{Address: to},
+				Amount:              &types.Amount{Value: "1000", Currency: cSol},
+			}},
+		},
+		{
+			opType: solanago.System__AssignWithSeed,
+			ops: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                solanago.System__AssignWithSeed,
+				Account:             &types.AccountIdentifier{Address: common.CreateWithSeed(base, "vault", common.TokenProgramID).ToBase58()},
+				Metadata:            map[string]interface{}{"base": base.ToBase58(), "seed": "vault", "owner": common.TokenProgramID.ToBase58()},
+			}},
+		},
+		{
+			opType: solanago.System__AllocateWithSeed,
+			ops: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                solanago.System__AllocateWithSeed,
+				Account:             &types.AccountIdentifier{Address: derived},
+				Metadata:            map[string]interface{}{"base": base.ToBase58(), "seed": "vault", "space": 10},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opType, func(t *testing.T) {
+			payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+				Operations: tt.ops,
+				Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+			})
+			assert.Assert(t, terr == nil)
+			assert.Equal(t, 1, len(payRes.Payloads))
+			assert.Equal(t, base.ToBase58(), payRes.Payloads[0].AccountIdentifier.Address)
+
+			parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				Transaction: payRes.UnsignedTransaction,
+			})
+			assert.Assert(t, terr == nil)
+			assert.Equal(t, len(tt.ops), len(parseRes.Operations))
+			for i, op := range parseRes.Operations {
+				assert.Equal(t, tt.opType, op.Type)
+				assert.Equal(t, tt.ops[i].Account.Address, op.Account.Address)
+				if tt.ops[i].Amount != nil {
+					assert.Equal(t, tt.ops[i].Amount.Value, op.Amount.Value)
+				}
+			}
+			// The metadata holds what the request passed.
+			want, _ := json.Marshal(tt.ops[0].Metadata)
+			got, _ := json.Marshal(parseRes.Operations[0].Metadata)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+
+	// The operations on the derived account need a base deriving it.
+	other := solPTypes.NewAccount().PublicKey.ToBase58()
+	for _, meta := range []map[string]interface{}{
+		{"seed": "vault"},
+		{"base": other, "seed": "vault"},
+		{"base": base.ToBase58(), "seed": "other"},
+		{"base": base.ToBase58(), "seed": "vault", "owner": common.TokenProgramID.ToBase58()},
+	} {
+		_, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Operations: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                solanago.System__AllocateWithSeed,
+				Account:             &types.AccountIdentifier{Address: derived},
+				Metadata:            meta,
+			}},
+			Metadata: map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+		})
+		assert.Equal(t, ErrUnclearIntent.Code, terr.Code, meta)
+	}
+
+	// A new account must be the one its base and seed derive.
+	createOps := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                solanago.System__CreateAccountWithSeed,
+		Account:             &types.AccountIdentifier{Address: base.ToBase58()},
+		Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+		Metadata:            map[string]interface{}{"seed": "other"},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                solanago.System__CreateAccountWithSeed,
+		Account:             &types.AccountIdentifier{Address: derived},
+		Amount:              &types.Amount{Value: "1000", Currency: cSol},
+	}}
+	_, terr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{Operations: createOps})
+	assert.Equal(t, ErrInvalidAddress.Code, terr.Code)
+	assert.DeepEqual(t, []int64{1}, terr.Details["indices"])
+	_, terr = s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: createOps,
+		Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+	})
+	assert.Equal(t, ErrInvalidAddress.Code, terr.Code)
+	assert.DeepEqual(t, []int64{1}, terr.Details["indices"])
+}
+
+func TestConstructionSystemOwner(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	from := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	program := solPTypes.NewAccount().PublicKey.ToBase58()
+	meta := map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"}
+	createAccount := func(opMeta map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__CreateAccount,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+			Metadata:            opMeta,
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__CreateAccount,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: cSol},
+		}}
+	}
+	assign := func(opMeta map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Assign,
+			Account:             &types.AccountIdentifier{Address: from},
+			Metadata:            opMeta,
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		ops   []*types.Operation
+		owner string
+	}{
+		{"create account", createAccount(map[string]interface{}{"owner": program, "space": 10}), program},
+		{"create account default", createAccount(map[string]interface{}{"space": 10}), ""},
+		{"assign", assign(map[string]interface{}{"owner": program}), program},
+		{"assign default", assign(nil), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+				Operations: tt.ops,
+				Metadata:   meta,
+			})
+			assert.Assert(t, terr == nil)
+			parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				Transaction: payRes.UnsignedTransaction,
+			})
+			assert.Assert(t, terr == nil)
+			assert.Equal(t, len(tt.ops), len(parseRes.Operations))
+			assert.Equal(t, from, parseRes.Operations[0].Account.Address)
+			// The default owner is left out like in the request.
+			owner, _ := parseRes.Operations[0].Metadata["owner"].(string)
+			assert.Equal(t, tt.owner, owner)
+
+			// The parsed operations construct the same transaction.
+			again, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+				Operations: parseRes.Operations,
+				Metadata:   meta,
+			})
+			assert.Assert(t, terr == nil)
+			assert.Equal(t, payRes.UnsignedTransaction, again.UnsignedTransaction)
+		})
+	}
+
+	_, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: assign(map[string]interface{}{"owner": "not a key"}),
+		Metadata:   meta,
+	})
+	assert.Equal(t, ErrInvalidAddress.Code, terr.Code)
+}
+
+func TestConstructionMemo(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	from := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	meta := map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"}
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: cSol},
+			Metadata:            map[string]interface{}{"memo": "deposit 42"},
+		}},
+		Metadata: meta,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, 1, len(payRes.Payloads))
+
+	parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	assert.DeepEqual(t, []string{"deposit 42"}, parseRes.Metadata[solanago.MemosKey])
+	assert.Equal(t, 2, len(parseRes.Operations))
+	assert.Equal(t, "deposit 42", parseRes.Operations[0].Metadata["memo"])
+	assert.Assert(t, parseRes.Operations[1].Metadata == nil)
+
+	again, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: parseRes.Operations,
+		Metadata:   meta,
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, payRes.UnsignedTransaction, again.UnsignedTransaction)
+}
+
+func TestNodeErrors(t *testing.T) {
+	ctx := context.Background()
+	preflightErr := func(txErr interface{}) *solanago.RPCError {
+		data, _ := json.Marshal(map[string]interface{}{"err": txErr, "logs": []string{}})
+		return &solanago.RPCError{Code: -32002, Message: "Transaction simulation failed", Data: data}
+	}
+	var sendErr *solanago.RPCError
+	client, rpc := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"sendTransaction": func([]interface{}) interface{} {
+			return sendErr
+		},
+		"getSlot": func(params []interface{}) interface{} {
+			return uint64(100)
+		},
+		"getBalance": func(params []interface{}) interface{} {
+			return &solanago.RPCError{Code: -32602, Message: "Invalid param: Invalid"}
+		},
+		"getEpochInfo": func([]interface{}) interface{} {
+			return httpStatus(http.StatusTooManyRequests)
+		},
+		"getConfirmedBlock": func([]interface{}) interface{} {
+			return &solanago.RPCError{Code: -32007, Message: "Slot 5 was skipped, or missing due to ledger jump to recent snapshot"}
+		},
+		"getHealth": func([]interface{}) interface{} {
+			return &solanago.RPCError{Code: -32005, Message: "Node is behind by 42 slots", Data: json.RawMessage(`{"numSlotsBehind":42}`)}
+		},
+	})
+	cfg := &configuration.Configuration{Mode: configuration.Online}
+	s := NewConstructionAPIService(cfg, client)
+	s.submitter.running = true
+	c := NewCallAPIService(cfg, client)
+	b := NewBlockAPIService(cfg, client)
+
+	signedTx, err := solanago.GetTxFromStr("64dq82ETBCJ9zzS6cUGqKc8L8bZ2ZTao3wR2nARKFqBywDccMta29VgGVNK2oza3nhoqidoUZczgyfNgmoTuYrdro3UXdwwVh5TVMx2CUzFUGUmmRmsaqJ1QnFxHQCUzhbroCddPPfvjw9edG3v1aetyNRknQtxgjXEjzkgn9EGtY3mo5XoRiw38qmwqACNkdsKqfNCcG5SC9mujtCoLaFXcmnVeAcdLMgBxXsTjv1JtiLpaWsB5g7TcEo2hLHL8sLV7ZiVsn66xA1ZBdAcFsLu572CHKQ8JJkgkX")
+	assert.NilError(t, err)
+	rawTx, err := signedTx.Serialize()
+	assert.NilError(t, err)
+	for _, tt := range []struct {
+		sendErr *solanago.RPCError
+		err     *types.Error
+	}{
+		{preflightErr("BlockhashNotFound"), ErrBlockhashNotFound},
+		{preflightErr("InsufficientFundsForFee"), ErrInsufficientFundsForFee},
+		{preflightErr("AccountNotFound"), ErrAccountNotFound},
+		{preflightErr(map[string]interface{}{"InstructionError": []interface{}{0, map[string]interface{}{"Custom": 1}}}), ErrTransactionFailed},
+		{&solanago.RPCError{Code: -32003, Message: "Transaction signature verification failure"}, ErrBroadcastFailed},
+	} {
+		sendErr = tt.sendErr
+		_, terr := s.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+			SignedTransaction: solanago.EncodeTx(rawTx, solanago.Base64Encoding),
+		})
+		assert.Assert(t, terr != nil)
+		assert.Equal(t, tt.err.Code, terr.Code)
+		assert.Equal(t, tt.err.Retriable, terr.Retriable)
+		assert.Equal(t, tt.sendErr.Code, terr.Details["code"])
+		assert.Equal(t, "sendTransaction", terr.Details["method"])
+	}
+	sendErr = preflightErr("BlockhashNotFound")
+	_, terr := s.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		SignedTransaction: solanago.EncodeTx(rawTx, solanago.Base64Encoding),
+	})
+	assert.Equal(t, "BlockhashNotFound", terr.Details["data"].(map[string]interface{})["err"])
+
+	// Methods called on the node through /call.
+	call := func(method string, parameters map[string]interface{}) (*types.CallResponse, *types.Error) {
+		return c.Call(ctx, &types.CallRequest{Method: method, Parameters: parameters})
+	}
+	res, terr := call("getSlot", map[string]interface{}{})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, float64(100), res.Result["result"])
+	_, terr = call("getBalance", map[string]interface{}{"param": "invalid"})
+	assert.Equal(t, ErrCallParametersInvalid.Code, terr.Code)
+	assert.Equal(t, -32602, terr.Details["code"])
+	_, terr = call("getSecretKey", nil)
+	assert.Equal(t, ErrCallMethodInvalid.Code, terr.Code)
+	assert.Equal(t, -32601, terr.Details["code"])
+
+	// The param is the one param of the method, a list included.
+	_, terr = call("getBalance", map[string]interface{}{"param": []interface{}{"a", "b"}})
+	assert.Equal(t, ErrCallParametersInvalid.Code, terr.Code)
+	last := rpc.requests[len(rpc.requests)-1]
+	assert.DeepEqual(t, []interface{}{[]interface{}{"a", "b"}}, last.Params)
+	_, terr = call("getEpochInfo", nil)
+	assert.Equal(t, ErrRateLimited.Code, terr.Code)
+	assert.Assert(t, terr.Retriable)
+	_, terr = call("getHealth", nil)
+	assert.Equal(t, ErrNodeBehind.Code, terr.Code)
+	assert.Assert(t, terr.Retriable)
+	assert.DeepEqual(t, map[string]interface{}{"numSlotsBehind": float64(42)}, terr.Details["data"])
+
+	index := int64(5)
+	_, terr = b.Block(ctx, &types.BlockRequest{BlockIdentifier: &types.PartialBlockIdentifier{Index: &index}})
+	assert.Equal(t, ErrSlotSkipped.Code, terr.Code)
+	assert.Assert(t, !terr.Retriable)
+}
+
+func TestConstructionCombineVerify(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	sender := solPTypes.NewAccount()
+	feePayer := solPTypes.NewAccount()
+	other := solPTypes.NewAccount()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: sender.PublicKey.ToBase58()},
+			Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: cSol},
+		}},
+		Metadata: map[string]interface{}{
+			"blockhash":          "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+			solanago.FeePayerKey: feePayer.PublicKey.ToBase58(),
+		},
+	})
+	assert.Assert(t, terr == nil)
+	message := payRes.Payloads[0].Bytes
+	sign := func(account solPTypes.Account, msg []byte) *types.Signature {
+		sig := &types.Signature{
+			PublicKey:     &types.PublicKey{Bytes: account.PublicKey.Bytes(), CurveType: types.Edwards25519},
+			SignatureType: types.Ed25519,
+		}
+		if msg != nil {
+			sig.Bytes = ed25519.Sign(account.PrivateKey, msg)
+		}
+		return sig
+	}
+
+	tests := []struct {
+		name       string
+		signatures []*types.Signature
+		context    string
+	}{
+		{"invalid signature", []*types.Signature{sign(sender, message), sign(feePayer, []byte("other"))}, "signature does not verify"},
+		{"unknown signer", []*types.Signature{sign(sender, message), sign(feePayer, message), sign(other, message)}, "unknown signer"},
+		{"duplicate signer", []*types.Signature{sign(sender, message), sign(sender, message), sign(feePayer, message)}, "duplicate signer"},
+		{"missing signer", []*types.Signature{sign(sender, message)}, "missing signers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+				UnsignedTransaction: payRes.UnsignedTransaction,
+				Signatures:          tt.signatures,
+			})
+			assert.Assert(t, terr != nil)
+			assert.Equal(t, ErrSignatureInvalid.Code, terr.Code)
+			assert.Equal(t, tt.context, terr.Details["context"])
+		})
+	}
+
+	// The sender signs first and defers the fee payer, which completes
+	// the partially signed transaction later.
+	partial, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		UnsignedTransaction: payRes.UnsignedTransaction,
+		Signatures:          []*types.Signature{sign(sender, message), sign(feePayer, nil)},
+	})
+	assert.Assert(t, terr == nil)
+	tx, err := solanago.GetTxFromStr(partial.SignedTransaction)
+	assert.NilError(t, err)
+	assert.Assert(t, !solanago.IsSigned(tx))
+
+	combRes, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		UnsignedTransaction: partial.SignedTransaction,
+		Signatures:          []*types.Signature{sign(feePayer, message)},
+	})
+	assert.Assert(t, terr == nil)
+	tx, err = solanago.GetTxFromStr(combRes.SignedTransaction)
+	assert.NilError(t, err)
+	assert.Assert(t, solanago.IsSigned(tx))
+}
+
+func TestTransactionHash(t *testing.T) {
+	_, terr := transactionHash(solPTypes.Transaction{})
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, terr.Code)
+
+	signature := bytes.Repeat([]byte{1}, 64)
+	hash, terr := transactionHash(solPTypes.Transaction{Signatures: []solPTypes.Signature{signature}})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, solPTypes.Signature(signature).ToBase58(), hash)
+}
+
+func TestConstructionEncoding(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	cfg := &configuration.Configuration{Mode: configuration.Offline, Encoding: solanago.Base64Encoding}
+	s := NewConstructionAPIService(cfg, nil)
+	sender := solPTypes.NewAccount()
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                solanago.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: sender.PublicKey.ToBase58()},
+		Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                solanago.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: solPTypes.NewAccount().PublicKey.ToBase58()},
+		Amount:              &types.Amount{Value: "1000", Currency: cSol},
+	}}
+
+	_, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{solanago.EncodingKey: "base32"},
+	})
+	assert.Equal(t, ErrUnsupportedEncoding.Code, terr.Code)
+	preRes, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{solanago.EncodingKey: solanago.Base58Encoding},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, solanago.Base58Encoding, preRes.Options[solanago.EncodingKey])
+
+	payloads := func(encoding string) *types.ConstructionPayloadsResponse {
+		metadata := map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"}
+		if len(encoding) > 0 {
+			metadata[solanago.EncodingKey] = encoding
+		}
+		payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Operations: ops,
+			Metadata:   metadata,
+		})
+		assert.Assert(t, terr == nil)
+		return payRes
+	}
+	// The configured encoding applies unless the metadata selects one.
+	base64Res := payloads("")
+	base58Res := payloads(solanago.Base58Encoding)
+	_, encoding, err := solanago.DecodeTx(base64Res.UnsignedTransaction)
+	assert.NilError(t, err)
+	assert.Equal(t, solanago.Base64Encoding, encoding)
+	_, encoding, err = solanago.DecodeTx(base58Res.UnsignedTransaction)
+	assert.NilError(t, err)
+	assert.Equal(t, solanago.Base58Encoding, encoding)
+	assert.DeepEqual(t, base58Res.Payloads, base64Res.Payloads)
+
+	_, terr = s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata: map[string]interface{}{
+			"blockhash":          "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+			solanago.EncodingKey: solanago.HexEncoding,
+		},
+	})
+	assert.Equal(t, ErrUnsupportedEncoding.Code, terr.Code)
+
+	// Combine keeps the encoding of the unsigned transaction and hash
+	// and parse read either.
+	var hashes []string
+	for _, payRes := range []*types.ConstructionPayloadsResponse{base64Res, base58Res} {
+		combRes, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+			UnsignedTransaction: payRes.UnsignedTransaction,
+			Signatures: []*types.Signature{{
+				SigningPayload: payRes.Payloads[0],
+				PublicKey:      &types.PublicKey{Bytes: sender.PublicKey.Bytes(), CurveType: types.Edwards25519},
+				SignatureType:  types.Ed25519,
+				Bytes:          ed25519.Sign(sender.PrivateKey, payRes.Payloads[0].Bytes),
+			}},
+		})
+		assert.Assert(t, terr == nil)
+		_, unsignedEncoding, err := solanago.DecodeTx(payRes.UnsignedTransaction)
+		assert.NilError(t, err)
+		_, signedEncoding, err := solanago.DecodeTx(combRes.SignedTransaction)
+		assert.NilError(t, err)
+		assert.Equal(t, unsignedEncoding, signedEncoding)
+
+		hashRes, terr := s.ConstructionHash(ctx, &types.ConstructionHashRequest{
+			SignedTransaction: combRes.SignedTransaction,
+		})
+		assert.Assert(t, terr == nil)
+		hashes = append(hashes, hashRes.TransactionIdentifier.Hash)
+
+		parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			Signed:      true,
+			Transaction: combRes.SignedTransaction,
+		})
+		assert.Assert(t, terr == nil)
+		assert.Equal(t, 2, len(parseRes.Operations))
+		assert.DeepEqual(t, []*types.AccountIdentifier{{Address: sender.PublicKey.ToBase58()}}, parseRes.AccountIdentifierSigners)
+	}
+	assert.Equal(t, hashes[0], hashes[1])
+}
+
+func TestConstructionParseRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	newAddress := func() string { return solPTypes.NewAccount().PublicKey.ToBase58() }
+	payer, owner, to, mint, nonce, program := newAddress(), newAddress(), newAddress(), newAddress(), newAddress(), newAddress()
+	fromToken, toToken, multisig := newAddress(), newAddress(), newAddress()
+	derived := common.CreateWithSeed(common.PublicKeyFromString(owner), "vault", common.SystemProgramID).ToBase58()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	c := &types.Currency{Symbol: mint, Decimals: 2}
+
+	single := func(opType string, account string, amount *types.Amount, meta map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: account},
+			Amount:              amount,
+			Metadata:            meta,
+		}}
+	}
+	pair := func(opType string, from string, to string, currency *types.Currency, meta map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-1000", Currency: currency},
+			Metadata:            meta,
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: currency},
+		}}
+	}
+	authority := map[string]interface{}{"authority": owner}
+	seed := map[string]interface{}{"base": owner, "seed": "vault"}
+
+	// The operations parse back as they were passed, but for the
+	// operations whose payloads metadata resolves them into other
+	// operations, which parse into parsed.
+	tests := []struct {
+		opType string
+		ops    []*types.Operation
+		meta   map[string]interface{}
+		parsed []*types.Operation
+	}{
+		{solanago.System__Transfer, pair(solanago.System__Transfer, owner, to, cSol, nil), nil, nil},
+		{solanago.System__CreateAccount, pair(solanago.System__CreateAccount, owner, to, cSol, map[string]interface{}{"space": 10, "owner": program}), nil, nil},
+		{solanago.System__Assign, single(solanago.System__Assign, owner, nil, map[string]interface{}{"owner": program}), nil, nil},
+		{solanago.System__CreateNonceAccount, single(solanago.System__CreateNonceAccount, owner, nil, map[string]interface{}{"destination": nonce, "lamports": 1447680}), nil, nil},
+		{solanago.System__AdvanceNonce, single(solanago.System__AdvanceNonce, owner, nil, map[string]interface{}{"destination": nonce}), nil, nil},
+		{solanago.System__WithdrawFromNonce, pair(solanago.System__WithdrawFromNonce, nonce, to, cSol, authority), nil, nil},
+		{solanago.System__AuthorizeNonce, single(solanago.System__AuthorizeNonce, owner, nil, map[string]interface{}{"destination": nonce, "new_authority": to}), nil, nil},
+		{solanago.System__Allocate, single(solanago.System__Allocate, owner, nil, map[string]interface{}{"space": 10}), nil, nil},
+		{solanago.System__CreateAccountWithSeed, pair(solanago.System__CreateAccountWithSeed, owner, derived, cSol, map[string]interface{}{"seed": "vault", "space": 10}), nil, nil},
+		{solanago.System__TransferWithSeed, pair(solanago.System__TransferWithSeed, derived, to, cSol, seed), nil, nil},
+		{solanago.System__AssignWithSeed, single(solanago.System__AssignWithSeed, common.CreateWithSeed(common.PublicKeyFromString(owner), "vault", common.PublicKeyFromString(program)).ToBase58(), nil, map[string]interface{}{"base": owner, "seed": "vault", "owner": program}), nil, nil},
+		{solanago.System__AllocateWithSeed, single(solanago.System__AllocateWithSeed, derived, nil, map[string]interface{}{"base": owner, "seed": "vault", "space": 10}), nil, nil},
+		{solanago.System__Sweep, single(solanago.System__Sweep, owner, nil, map[string]interface{}{"destination": to}), map[string]interface{}{
+			"sweeps": map[string]interface{}{"0": solanago.Sweep{Source: owner, Destination: to, Amount: 500}},
+		}, []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: owner},
+			Amount:              &types.Amount{Value: "-500", Currency: cSol},
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "500", Currency: cSol},
+		}}},
+		{solanago.SplToken__Transfer, single(solanago.SplToken__Transfer, fromToken, nil, map[string]interface{}{"destination": toToken, "amount": 1000, "authority": owner}), nil, nil},
+		{solanago.SplToken__InitializeMint, single(solanago.SplToken__InitializeMint, owner, nil, map[string]interface{}{"mint": mint, "decimals": 2}), nil, nil},
+		{solanago.SplToken__InitializeAccount, single(solanago.SplToken__InitializeAccount, owner, nil, map[string]interface{}{"destination": fromToken, "mint": mint}), nil, nil},
+		{solanago.SplToken__InitializeMultisig, single(solanago.SplToken__InitializeMultisig, multisig, nil, map[string]interface{}{"signers": []string{owner, to}, "m": 1}), nil, nil},
+		{solanago.SplToken__CreateToken, single(solanago.SplToken__CreateToken, owner, nil, map[string]interface{}{"mint": mint, "amount": 1461600, "decimals": 2}), nil, nil},
+		{solanago.SplToken__CreateAccount, single(solanago.SplToken__CreateAccount, owner, nil, map[string]interface{}{"destination": fromToken, "mint": mint, "amount": 2039280}), nil, nil},
+		{solanago.SplToken__Approve, single(solanago.SplToken__Approve, fromToken, nil, map[string]interface{}{"destination": to, "amount": 5, "authority": owner}), nil, nil},
+		{solanago.SplToken__Revoke, single(solanago.SplToken__Revoke, fromToken, nil, authority), nil, nil},
+		{solanago.SplToken__MintTo, single(solanago.SplToken__MintTo, fromToken, nil, map[string]interface{}{"mint": mint, "amount": 150, "authority": owner}), nil, nil},
+		{solanago.SplToken__MintToChecked, single(solanago.SplToken__MintToChecked, fromToken, &types.Amount{Value: "150", Currency: c}, authority), nil, nil},
+		{solanago.SplToken__Burn, single(solanago.SplToken__Burn, fromToken, nil, map[string]interface{}{"mint": mint, "amount": 150, "authority": owner}), nil, nil},
+		{solanago.SplToken__BurnChecked, single(solanago.SplToken__BurnChecked, fromToken, &types.Amount{Value: "-150", Currency: c}, authority), nil, nil},
+		{solanago.SplToken__CloseAccount, single(solanago.SplToken__CloseAccount, fromToken, nil, map[string]interface{}{"destination": to, "authority": owner}), nil, nil},
+		{solanago.SplToken__FreezeAccount, single(solanago.SplToken__FreezeAccount, fromToken, nil, map[string]interface{}{"mint": mint, "authority": owner}), nil, nil},
+		{solanago.SplToken__ThawAccount, single(solanago.SplToken__ThawAccount, fromToken, nil, map[string]interface{}{"mint": mint, "authority": owner}), nil, nil},
+		{solanago.SplToken__SetAuthority, single(solanago.SplToken__SetAuthority, mint, nil, map[string]interface{}{
+			"authority": owner, "authority_type": "mintTokens", "new_authority": to,
+		}), nil, nil},
+		{solanago.SplToken__SyncNative, single(solanago.SplToken__SyncNative, fromToken, nil, nil), nil, nil},
+		{solanago.SplToken__WrapSol, single(solanago.SplToken__WrapSol, owner, &types.Amount{Value: "-5000", Currency: cSol}, nil), nil, nil},
+		{solanago.SplToken__UnwrapSol, single(solanago.SplToken__UnwrapSol, owner, nil, nil), nil, nil},
+		{solanago.SplToken__Sweep, single(solanago.SplToken__Sweep, owner, nil, map[string]interface{}{"destination": to, "mint": mint}), map[string]interface{}{
+			"sweeps": map[string]interface{}{"0": solanago.Sweep{Source: owner, Destination: to, Mint: mint, Amount: 250, Decimals: 2}},
+		}, nil},
+		{solanago.SplToken__TransferChecked, pair(solanago.SplToken__TransferChecked, fromToken, toToken, c, authority), nil, nil},
+		{solanago.SplToken__TransferNew, pair(solanago.SplToken__TransferNew, fromToken, to, c, authority), nil, nil},
+		{solanago.SplToken__TransferWithSystem, pair(solanago.SplToken__TransferWithSystem, owner, to, c, nil), nil, nil},
+		{solanago.SplAssociatedTokenAccount__Create, single(solanago.SplAssociatedTokenAccount__Create, owner, nil, map[string]interface{}{"wallet": to, "mint": mint}), nil, nil},
+	}
+
+	covered := map[string]bool{solanago.Unknown: true}
+	for _, tt := range tests {
+		covered[tt.opType] = true
+		t.Run(tt.opType, func(t *testing.T) {
+			meta := map[string]interface{}{
+				"blockhash":          "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				solanago.FeePayerKey: payer,
+			}
+			for k, v := range tt.meta {
+				meta[k] = v
+			}
+			payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+				Operations: tt.ops,
+				Metadata:   meta,
+			})
+			assert.Assert(t, terr == nil)
+			parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				Transaction: payRes.UnsignedTransaction,
+			})
+			assert.Assert(t, terr == nil)
+			assert.Equal(t, 0, len(parseRes.AccountIdentifierSigners))
+			for _, op := range parseRes.Operations {
+				assert.Assert(t, op.Status == nil)
+			}
+			parsed := tt.parsed
+			if parsed == nil {
+				parsed = tt.ops
+			}
+			want, _ := json.Marshal(parsed)
+			got, _ := json.Marshal(parseRes.Operations)
+			assert.Equal(t, string(want), string(got))
+
+			// The parsed operations construct the same transaction.
+			again, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+				Operations: parseRes.Operations,
+				Metadata:   meta,
+			})
+			assert.Assert(t, terr == nil)
+			assert.Equal(t, payRes.UnsignedTransaction, again.UnsignedTransaction)
+		})
+	}
+	for _, opType := range solanago.OperationTypes {
+		assert.Assert(t, covered[opType], opType)
+	}
+}
+
+func TestConstructionParseUnknownInstruction(t *testing.T) {
+	ctx := context.Background()
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	payer := solPTypes.NewAccount().PublicKey
+
+	tx, _, terr := buildTransaction([]solPTypes.Instruction{{
+		ProgramID: solPTypes.NewAccount().PublicKey,
+		Accounts:  []solPTypes.AccountMeta{{PubKey: payer, IsSigner: true, IsWritable: true}},
+		Data:      []byte{1, 2, 3},
+	}}, placeholderBlockhash, nil, payer.ToBase58())
+	assert.Assert(t, terr == nil)
+	b, terr := serializeTransaction(tx)
+	assert.Assert(t, terr == nil)
+
+	// An instruction no operation builds is not parsed.
+	_, terr = s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: solanago.EncodeTx(b, solanago.Base58Encoding),
+	})
+	assert.Assert(t, terr != nil)
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, terr.Code)
+}
+
+func TestConstructionParseSigners(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	sender := solPTypes.NewAccount()
+	feePayer := solPTypes.NewAccount()
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: sender.PublicKey.ToBase58()},
+			Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: solPTypes.NewAccount().PublicKey.ToBase58()},
+			Amount:              &types.Amount{Value: "1000", Currency: cSol},
+		}},
+		Metadata: map[string]interface{}{
+			"blockhash":          "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+			solanago.FeePayerKey: feePayer.PublicKey.ToBase58(),
+		},
+	})
+	assert.Assert(t, terr == nil)
+	message := payRes.Payloads[0].Bytes
+	sign := func(account solPTypes.Account, signed bool) *types.Signature {
+		sig := &types.Signature{
+			PublicKey:     &types.PublicKey{Bytes: account.PublicKey.Bytes(), CurveType: types.Edwards25519},
+			SignatureType: types.Ed25519,
+		}
+		if signed {
+			sig.Bytes = ed25519.Sign(account.PrivateKey, message)
+		}
+		return sig
+	}
+	partial, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		UnsignedTransaction: payRes.UnsignedTransaction,
+		Signatures:          []*types.Signature{sign(sender, true), sign(feePayer, false)},
+	})
+	assert.Assert(t, terr == nil)
+	full, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		UnsignedTransaction: payRes.UnsignedTransaction,
+		Signatures:          []*types.Signature{sign(sender, true), sign(feePayer, true)},
+	})
+	assert.Assert(t, terr == nil)
+
+	tests := []struct {
+		name        string
+		transaction string
+		signed      bool
+		signers     []string
+	}{
+		{"unsigned", payRes.UnsignedTransaction, false, nil},
+		{"unsigned as signed", payRes.UnsignedTransaction, true, nil},
+		{"signed as unsigned", full.SignedTransaction, false, nil},
+		{"partially signed", partial.SignedTransaction, true, []string{sender.PublicKey.ToBase58()}},
+		{"signed", full.SignedTransaction, true, []string{feePayer.PublicKey.ToBase58(), sender.PublicKey.ToBase58()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				Signed:      tt.signed,
+				Transaction: tt.transaction,
+			})
+			assert.Assert(t, terr == nil)
+			var signers []string
+			for _, v := range parseRes.AccountIdentifierSigners {
+				signers = append(signers, v.Address)
+			}
+			assert.DeepEqual(t, tt.signers, signers)
+			assert.Equal(t, 2, len(parseRes.Operations))
+		})
+	}
+}
+
+func TestCommitment(t *testing.T) {
+	ctx := context.Background()
+	owner := solPTypes.NewAccount().PublicKey.ToBase58()
+	client, rpc := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getRecentBlockhash": func([]interface{}) interface{} {
+			return rpcContext(100, map[string]interface{}{
+				"blockhash":     "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				"feeCalculator": map[string]interface{}{"lamportsPerSignature": 5000},
+			})
+		},
+		"getBalance": func([]interface{}) interface{} {
+			return rpcContext(100, 1000000)
+		},
+		"getTokenAccountsByOwner": func([]interface{}) interface{} {
+			return rpcContext(100, []interface{}{})
+		},
+		"getSlot": func([]interface{}) interface{} {
+			return uint64(100)
+		},
+		"getConfirmedBlock": func([]interface{}) interface{} {
+			return map[string]interface{}{
+				"blockhash":         "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				"previousBlockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				"parentSlot":        99,
+				"blockTime":         1600000000,
+				"transactions":      []interface{}{},
+			}
+		},
+		"sendTransaction": func([]interface{}) interface{} {
+			return "sig"
+		},
+	})
+	cfg := &configuration.Configuration{Mode: configuration.Online}
+	lastParams := func(method string) []interface{} {
+		var params []interface{}
+		for _, req := range rpc.requests {
+			if req.Method == method {
+				params = req.Params
+			}
+		}
+		return params
+	}
+
+	// Without a commitment the node default applies.
+	a := NewAccountAPIService(cfg, client)
+	balanceRes, terr := a.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{Address: owner},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Assert(t, balanceRes.Metadata == nil)
+	assert.DeepEqual(t, []interface{}{owner}, lastParams("getBalance"))
+
+	balanceRes, terr = a.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{
+			Address:  owner,
+			Metadata: map[string]interface{}{"commitment": "confirmed"},
+		},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, "confirmed", balanceRes.Metadata["commitment"])
+	assert.DeepEqual(t, []interface{}{owner, map[string]interface{}{"commitment": "confirmed"}}, lastParams("getBalance"))
+	assert.DeepEqual(t, []interface{}{map[string]interface{}{"commitment": "confirmed"}}, lastParams("getSlot"))
+
+	_, terr = a.AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{
+			Address:  owner,
+			Metadata: map[string]interface{}{"commitment": "max"},
+		},
+	})
+	assert.Equal(t, ErrUnsupportedCommitment.Code, terr.Code)
+
+	// Balances are not reported without the slot they are read at.
+	noSlot, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getBalance": func([]interface{}) interface{} {
+			return rpcContext(100, 1000000)
+		},
+		"getTokenAccountsByOwner": func([]interface{}) interface{} {
+			return rpcContext(100, []interface{}{})
+		},
+	})
+	_, terr = NewAccountAPIService(cfg, noSlot).AccountBalance(ctx, &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{Address: owner},
+	})
+	assert.Assert(t, terr != nil)
+
+	// Blocks are not read at processed.
+	b := NewBlockAPIService(cfg, client)
+	index := int64(100)
+	blockRes, terr := b.Block(solanago.WithCommitment(ctx, solanago.ProcessedCommitment), &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{Index: &index},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, "confirmed", blockRes.Block.Metadata["commitment"])
+	assert.DeepEqual(t, map[string]interface{}{"encoding": "jsonParsed", "commitment": "confirmed"}, lastParams("getConfirmedBlock")[1])
+
+	// The commitment of preprocess is passed on to metadata.
+	s := NewConstructionAPIService(cfg, client)
+	s.submitter.running = true
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                solanago.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: owner},
+		Amount:              &types.Amount{Value: "-1000", Currency: solanago.Currency},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                solanago.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: solPTypes.NewAccount().PublicKey.ToBase58()},
+		Amount:              &types.Amount{Value: "1000", Currency: solanago.Currency},
+	}}
+	_, terr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{"commitment": "recent"},
+	})
+	assert.Equal(t, ErrUnsupportedCommitment.Code, terr.Code)
+	preRes, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{"commitment": "confirmed"},
+	})
+	assert.Assert(t, terr == nil)
+	options := map[string]interface{}{}
+	raw, _ := json.Marshal(preRes.Options)
+	json.Unmarshal(raw, &options)
+	metaRes, terr := s.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{Options: options})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, "confirmed", metaRes.Metadata["commitment"])
+	assert.DeepEqual(t, []interface{}{map[string]interface{}{"commitment": "confirmed"}}, lastParams("getRecentBlockhash"))
+
+	// Preflight checks no longer use the deprecated max commitment.
+	signedTx, err := solanago.GetTxFromStr("64dq82ETBCJ9zzS6cUGqKc8L8bZ2ZTao3wR2nARKFqBywDccMta29VgGVNK2oza3nhoqidoUZczgyfNgmoTuYrdro3UXdwwVh5TVMx2CUzFUGUmmRmsaqJ1QnFxHQCUzhbroCddPPfvjw9edG3v1aetyNRknQtxgjXEjzkgn9EGtY3mo5XoRiw38qmwqACNkdsKqfNCcG5SC9mujtCoLaFXcmnVeAcdLMgBxXsTjv1JtiLpaWsB5g7TcEo2hLHL8sLV7ZiVsn66xA1ZBdAcFsLu572CHKQ8JJkgkX")
+	assert.NilError(t, err)
+	rawTx, err := signedTx.Serialize()
+	assert.NilError(t, err)
+	_, terr = s.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		SignedTransaction: solanago.EncodeTx(rawTx, solanago.Base58Encoding),
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, "confirmed", lastParams("sendTransaction")[1].(map[string]interface{})["preflightCommitment"])
+}
+
+func TestBlockCache(t *testing.T) {
+	ctx := context.Background()
+	blockhash := func(slot int64) string {
+		return fmt.Sprintf("hash%d", slot)
+	}
+	client, rpc := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getConfirmedBlock": func(params []interface{}) interface{} {
+			slot := int64(params[0].(float64))
+			return map[string]interface{}{
+				"blockhash":         blockhash(slot),
+				"previousBlockhash": blockhash(slot - 1),
+				"parentSlot":        slot - 1,
+				"blockTime":         1600000000,
+				"transactions": []interface{}{map[string]interface{}{
+					"transaction": map[string]interface{}{
+						"signatures": []interface{}{fmt.Sprintf("sig%d", slot)},
+						"message":    map[string]interface{}{"accountKeys": []interface{}{}, "instructions": []interface{}{}},
+					},
+				}},
+			}
+		},
+	})
+	fetches := func() int {
+		n := 0
+		for _, req := range rpc.requests {
+			if req.Method == "getConfirmedBlock" || req.Method == "getConfirmedTransaction" {
+				n++
+			}
+		}
+		return n
+	}
+	cfg := &configuration.Configuration{
+		Mode:               configuration.Online,
+		BlockCacheSize:     2,
+		BlockCacheDir:      t.TempDir(),
+		BlockCacheDiskSize: 2,
+	}
+	b := NewBlockAPIService(cfg, client)
+	now := time.Unix(1600000000, 0)
+	b.cache.now = func() time.Time { return now }
+	block := func(ctx context.Context, slot int64) *types.Block {
+		res, terr := b.Block(ctx, &types.BlockRequest{
+			BlockIdentifier: &types.PartialBlockIdentifier{Index: &slot},
+		})
+		assert.Assert(t, terr == nil)
+		assert.Equal(t, blockhash(slot), res.Block.BlockIdentifier.Hash)
+		return res.Block
+	}
+
+	// Finalized blocks are fetched once, then served by slot. Blocks
+	// the node cannot read by hash are not served by hash either.
+	block(ctx, 1)
+	block(ctx, 1)
+	hash := blockhash(1)
+	_, terr := b.Block(ctx, &types.BlockRequest{
+		BlockIdentifier: &types.PartialBlockIdentifier{Hash: &hash},
+	})
+	assert.Assert(t, terr != nil)
+	txRes, terr := b.BlockTransaction(ctx, &types.BlockTransactionRequest{
+		BlockIdentifier:       &types.BlockIdentifier{Index: 1, Hash: hash},
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "sig1"},
+	})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, "sig1", txRes.Transaction.TransactionIdentifier.Hash)
+	assert.Equal(t, 1, fetches())
+
+	// Evicted blocks are spilled to the disk and read back.
+	block(ctx, 2)
+	block(ctx, 3)
+	block(ctx, 1)
+	assert.Equal(t, 3, fetches())
+
+	// Blocks read below finalized are not served to finalized reads and
+	// expire.
+	confirmed := solanago.WithCommitment(ctx, solanago.ConfirmedCommitment)
+	block(confirmed, 10)
+	block(confirmed, 10)
+	assert.Equal(t, 4, fetches())
+	block(ctx, 10)
+	assert.Equal(t, 5, fetches())
+	block(confirmed, 11)
+	now = now.Add(unfinalizedBlockTTL + time.Second)
+	block(confirmed, 11)
+	assert.Equal(t, 7, fetches())
+
+	c := NewCallAPIService(cfg, client)
+	c.blockCache = b.cache
+	callRes, terr := c.Call(ctx, &types.CallRequest{Method: solanago.CallBlockCacheStats})
+	assert.Assert(t, terr == nil)
+	var stats BlockCacheStats
+	assert.NilError(t, unmarshalJSONMap(callRes.Result, &stats))
+	assert.DeepEqual(t, BlockCacheStats{
+		Hits:          3,
+		DiskHits:      1,
+		Misses:        7,
+		Evictions:     4,
+		Invalidations: 1,
+		Blocks:        2,
+		DiskBlocks:    2,
+	}, stats)
+
+	// Spilled blocks outlive the cache.
+	cache, err := newBlockCache(2, cfg.BlockCacheDir, 2)
+	assert.NilError(t, err)
+	one := int64(1)
+	_, ok := cache.get(&types.PartialBlockIdentifier{Index: &one, Hash: &hash}, "")
+	assert.Assert(t, ok)
+}
+
+func TestMetrics(t *testing.T) {
+	client, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getConfirmedBlock": func(params []interface{}) interface{} {
+			slot := int64(params[0].(float64))
+			if slot == 5 {
+				return &solanago.RPCError{Code: -32007, Message: "Slot 5 was skipped, or missing due to ledger jump to recent snapshot"}
+			}
+			return map[string]interface{}{
+				"blockhash":         fmt.Sprintf("hash%d", slot),
+				"previousBlockhash": fmt.Sprintf("hash%d", slot-1),
+				"parentSlot":        slot - 1,
+				"blockTime":         1600000000,
+				"transactions":      []interface{}{},
+			}
+		},
+	})
+	network := &types.NetworkIdentifier{Blockchain: solanago.Blockchain, Network: "devnet"}
+	cfg := &configuration.Configuration{
+		Mode:           configuration.Online,
+		Network:        network,
+		BlockCacheSize: 2,
+	}
+	a, err := asserter.NewServer(
+		solanago.OperationTypes,
+		solanago.HistoricalBalanceSupported,
+		[]*types.NetworkIdentifier{network},
+		solanago.CallMethods,
+		false,
+	)
+	assert.NilError(t, err)
+	router := MetricsMiddleware(NewBlockchainRouter(cfg, client, a))
+
+	post := func(path string, request interface{}) int {
+		body, err := json.Marshal(request)
+		assert.NilError(t, err)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+		return res.Code
+	}
+	block := func(index int64) int {
+		return post("/block", &types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &index},
+		})
+	}
+	assert.Equal(t, http.StatusOK, block(4))
+	assert.Equal(t, http.StatusOK, block(4))
+	assert.Equal(t, http.StatusInternalServerError, block(5))
+	assert.Equal(t, http.StatusNotFound, post("/unknown", nil))
+
+	res := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	scraped := res.Body.String()
+	for _, line := range []string{
+		`rosetta_requests_total{endpoint="/block",status="200"} 2`,
+		`rosetta_requests_total{endpoint="/block",status="500"} 1`,
+		`rosetta_requests_total{endpoint="other",status="404"} 1`,
+		fmt.Sprintf(`rosetta_request_errors_total{code="%d",endpoint="/block"} 1`, ErrSlotSkipped.Code),
+		`rosetta_request_duration_seconds_count{endpoint="/block"} 3`,
+		`rosetta_block_cache_hits_total 1`,
+		`rosetta_block_cache_misses_total 2`,
+		`rosetta_block_cache_blocks 1`,
+	} {
+		assert.Assert(t, strings.Contains(scraped, line+"\n"), line)
+	}
+	assert.Assert(t, strings.Contains(scraped, `solana_rpc_request_duration_seconds_count{method="getConfirmedBlock"}`))
+}
+
+func TestLogging(t *testing.T) {
+	client, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getGenesisHash":         func([]interface{}) interface{} { return "genesis" },
+		"getFirstAvailableBlock": func([]interface{}) interface{} { return 0 },
+		"getRecentBlockhash": func([]interface{}) interface{} {
+			return rpcContext(100, map[string]interface{}{
+				"blockhash":     "hash100",
+				"feeCalculator": map[string]interface{}{"lamportsPerSignature": 5000},
+			})
+		},
+		"getSlot":      func([]interface{}) interface{} { return 100 },
+		"getBlockTime": func([]interface{}) interface{} { return 1600000000 },
+	})
+	network := &types.NetworkIdentifier{Blockchain: solanago.Blockchain, Network: "devnet"}
+	cfg := &configuration.Configuration{Mode: configuration.Online, Network: network}
+	a, err := asserter.NewServer(
+		solanago.OperationTypes,
+		solanago.HistoricalBalanceSupported,
+		[]*types.NetworkIdentifier{network},
+		solanago.CallMethods,
+		false,
+	)
+	assert.NilError(t, err)
+	var out bytes.Buffer
+	router := LoggingMiddleware(logger.New(&out, logger.DebugLevel), NewBlockchainRouter(cfg, client, a))
+
+	body, err := json.Marshal(&types.NetworkRequest{NetworkIdentifier: network})
+	assert.NilError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/network/status", bytes.NewReader(body))
+	req.Header.Set(RequestIDHeader, "req-1")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "req-1", res.Header().Get(RequestIDHeader))
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		assert.NilError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "req-1", entry["request_id"])
+		assert.Equal(t, "/network/status", entry["endpoint"])
+		assert.DeepEqual(t, map[string]interface{}{"blockchain": "solana", "network": "devnet"}, entry["network"])
+		entries = append(entries, entry)
+	}
+	find := func(msg string, key string, value interface{}) map[string]interface{} {
+		for _, entry := range entries {
+			if entry["msg"] == msg && entry[key] == value {
+				return entry
+			}
+		}
+		t.Fatalf("no %s entry with %s %v in %s", msg, key, value, out.String())
+		return nil
+	}
+
+	// Calls to the node are traced, and errors the status is reported
+	// without are logged.
+	find("rpc call", "rpc_method", "getSlot")
+	find("rpc call failed", "rpc_method", "getClusterNodes")
+	assert.Equal(t, "Method not found", find("unable to get cluster nodes", "level", "warn")["error"])
+	assert.Equal(t, float64(http.StatusOK), find("request served", "level", "info")["status"])
+
+	// Requests without an id are given one.
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/network/list", bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, 16, len(res.Header().Get(RequestIDHeader)))
+}
+
+func TestSimulateSlot(t *testing.T) {
+	ctx := context.Background()
+	accountsSlots := []uint64{100, 101}
+	simulateSlots := []uint64{101, 101}
+	account := map[string]interface{}{"lamports": 1000000, "owner": "11111111111111111111111111111111", "data": []interface{}{"", "base64"}}
+	accounts := func(n int) []interface{} {
+		var v []interface{}
+		for i := 0; i < n; i++ {
+			v = append(v, account)
+		}
+		return v
+	}
+	client, rpc := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getMultipleAccounts": func(params []interface{}) interface{} {
+			slot := accountsSlots[0]
+			accountsSlots = accountsSlots[1:]
+			return rpcContext(slot, accounts(len(params[0].([]interface{}))))
+		},
+		"simulateTransaction": func(params []interface{}) interface{} {
+			slot := simulateSlots[0]
+			simulateSlots = simulateSlots[1:]
+			addresses := params[1].(map[string]interface{})["accounts"].(map[string]interface{})["addresses"].([]interface{})
+			return rpcContext(slot, map[string]interface{}{"err": nil, "logs": []string{}, "accounts": accounts(len(addresses))})
+		},
+	})
+	c := NewCallAPIService(&configuration.Configuration{Mode: configuration.Online}, client)
+	tx := "64dq82ETBCJ9zzS6cUGqKc8L8bZ2ZTao3wR2nARKFqBywDccMta29VgGVNK2oza3nhoqidoUZczgyfNgmoTuYrdro3UXdwwVh5TVMx2CUzFUGUmmRmsaqJ1QnFxHQCUzhbroCddPPfvjw9edG3v1aetyNRknQtxgjXEjzkgn9EGtY3mo5XoRiw38qmwqACNkdsKqfNCcG5SC9mujtCoLaFXcmnVeAcdLMgBxXsTjv1JtiLpaWsB5g7TcEo2hLHL8sLV7ZiVsn66xA1ZBdAcFsLu572CHKQ8JJkgkX"
+	simulate := func() (*types.CallResponse, *types.Error) {
+		return c.Call(ctx, &types.CallRequest{
+			Method:     solanago.CallSimulate,
+			Parameters: map[string]interface{}{"transaction": tx},
+		})
+	}
+
+	// The accounts are read again at the slot of the simulation.
+	res, terr := simulate()
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, float64(101), res.Result["slot"])
+	var minSlots []interface{}
+	for _, req := range rpc.requests {
+		config := req.Params[len(req.Params)-1].(map[string]interface{})
+		minSlots = append(minSlots, config["minContextSlot"])
+	}
+	assert.DeepEqual(t, []interface{}{nil, float64(100), float64(101), float64(101)}, minSlots)
+
+	// A simulation the accounts cannot be read at is rejected.
+	accountsSlots = []uint64{100, 101, 102}
+	simulateSlots = []uint64{101, 102, 103}
+	_, terr = simulate()
+	assert.Equal(t, ErrGeth.Code, terr.Code)
+	assert.Assert(t, strings.Contains(terr.Details["context"].(string), "slot mismatch"))
+}
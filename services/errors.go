@@ -15,7 +15,11 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
 )
 
 var (
@@ -35,6 +39,27 @@ var (
 		ErrCallMethodInvalid,
 		ErrInvalidAddress,
 		ErrGethNotReady,
+		ErrInsufficientBalance,
+		ErrTokenAccountNotFound,
+		ErrInvalidTokenAccount,
+		ErrInvalidRecipient,
+		ErrNonceAccountNotFound,
+		ErrNonceNotInitialized,
+		ErrNonceAuthorityMismatch,
+		ErrTransactionTooLarge,
+		ErrTooManyAccounts,
+		ErrInvalidSeed,
+		ErrNoncePoolExhausted,
+		ErrUnsupportedEncoding,
+		ErrBlockhashNotFound,
+		ErrInsufficientFundsForFee,
+		ErrAccountNotFound,
+		ErrSlotSkipped,
+		ErrNodeBehind,
+		ErrRateLimited,
+		ErrInvalidParams,
+		ErrTransactionFailed,
+		ErrUnsupportedCommitment,
 	}
 
 	// ErrUnimplemented is returned when an endpoint
@@ -83,7 +108,8 @@ var (
 	}
 
 	// ErrSignatureInvalid is returned when a signature
-	// cannot be parsed.
+	// cannot be parsed, does not verify or when signers
+	// are missing, duplicated or unknown.
 	ErrSignatureInvalid = &types.Error{
 		Code:    6, //nolint
 		Message: "Signature invalid",
@@ -132,6 +158,170 @@ var (
 		Message:   "node not ready",
 		Retriable: true,
 	}
+
+	// ErrInsufficientBalance is returned by /construction/metadata
+	// when an account cannot cover the amounts it sends plus the fee.
+	ErrInsufficientBalance = &types.Error{
+		Code:    14, //nolint
+		Message: "Insufficient balance",
+	}
+
+	// ErrTokenAccountNotFound is returned when no token account
+	// exists for a mint and owner used in construction.
+	ErrTokenAccountNotFound = &types.Error{
+		Code:    15, //nolint
+		Message: "Token account not found",
+	}
+
+	// ErrInvalidTokenAccount is returned when an account used as a
+	// token account is not a token account of the requested mint.
+	ErrInvalidTokenAccount = &types.Error{
+		Code:    16, //nolint
+		Message: "Invalid token account",
+	}
+
+	// ErrInvalidRecipient is returned when the receiver of a
+	// transfer is not the expected kind of account.
+	ErrInvalidRecipient = &types.Error{
+		Code:    17, //nolint
+		Message: "Invalid recipient account",
+	}
+
+	// ErrNonceAccountNotFound is returned when the durable
+	// nonce account does not exist.
+	ErrNonceAccountNotFound = &types.Error{
+		Code:    18, //nolint
+		Message: "Nonce account not found",
+	}
+
+	// ErrNonceNotInitialized is returned when the durable
+	// nonce account is not an initialized nonce account.
+	ErrNonceNotInitialized = &types.Error{
+		Code:    19, //nolint
+		Message: "Nonce account not initialized",
+	}
+
+	// ErrNonceAuthorityMismatch is returned when the requested
+	// nonce authority is not the authority of the nonce account.
+	ErrNonceAuthorityMismatch = &types.Error{
+		Code:    20, //nolint
+		Message: "Nonce authority mismatch",
+	}
+
+	// ErrTransactionTooLarge is returned when the transaction
+	// built from the requested operations does not fit in a packet.
+	ErrTransactionTooLarge = &types.Error{
+		Code:    21, //nolint
+		Message: "Transaction too large",
+	}
+
+	// ErrTooManyAccounts is returned when the transaction built
+	// from the requested operations references too many accounts.
+	ErrTooManyAccounts = &types.Error{
+		Code:    22, //nolint
+		Message: "Transaction references too many accounts",
+	}
+
+	// ErrInvalidSeed is returned when the seed of a
+	// seed-derived address is too long.
+	ErrInvalidSeed = &types.Error{
+		Code:    23, //nolint
+		Message: "Invalid seed",
+	}
+
+	// ErrNoncePoolExhausted is returned by /construction/metadata
+	// when every pooled nonce account of the authority is in use.
+	ErrNoncePoolExhausted = &types.Error{
+		Code:      24, //nolint
+		Message:   "Nonce pool exhausted",
+		Retriable: true,
+	}
+
+	// ErrUnsupportedEncoding is returned when the requested
+	// transaction encoding is not base58 or base64.
+	ErrUnsupportedEncoding = &types.Error{
+		Code:    25, //nolint
+		Message: "Unsupported transaction encoding",
+	}
+
+	// ErrBlockhashNotFound is returned when the node does not know
+	// the blockhash of a transaction, usually because it expired.
+	// The transaction has to be built again with new metadata.
+	ErrBlockhashNotFound = &types.Error{
+		Code:    26, //nolint
+		Message: "Blockhash not found or expired",
+	}
+
+	// ErrInsufficientFundsForFee is returned when the fee payer
+	// of a transaction cannot pay its fee.
+	ErrInsufficientFundsForFee = &types.Error{
+		Code:    27, //nolint
+		Message: "Insufficient funds for fee",
+	}
+
+	// ErrAccountNotFound is returned when an account a transaction
+	// debits does not exist.
+	ErrAccountNotFound = &types.Error{
+		Code:    28, //nolint
+		Message: "Account not found",
+	}
+
+	// ErrSlotSkipped is returned when the requested slot was
+	// skipped and holds no block.
+	ErrSlotSkipped = &types.Error{
+		Code:    29, //nolint
+		Message: "Slot skipped",
+	}
+
+	// ErrNodeBehind is returned when the node is behind the
+	// cluster or does not have the requested data yet.
+	ErrNodeBehind = &types.Error{
+		Code:      30, //nolint
+		Message:   "Node behind",
+		Retriable: true,
+	}
+
+	// ErrRateLimited is returned when the node rate limits
+	// requests.
+	ErrRateLimited = &types.Error{
+		Code:      31, //nolint
+		Message:   "Rate limited",
+		Retriable: true,
+	}
+
+	// ErrInvalidParams is returned when the node rejects the
+	// parameters of a request.
+	ErrInvalidParams = &types.Error{
+		Code:    32, //nolint
+		Message: "Invalid params",
+	}
+
+	// ErrTransactionFailed is returned when the preflight
+	// simulation of a submitted transaction fails.
+	ErrTransactionFailed = &types.Error{
+		Code:    33, //nolint
+		Message: "Transaction simulation failed",
+	}
+
+	// ErrUnsupportedCommitment is returned when the requested
+	// commitment is not processed, confirmed or finalized.
+	ErrUnsupportedCommitment = &types.Error{
+		Code:    34, //nolint
+		Message: "Unsupported commitment",
+	}
+
+	// nodeErrors are the errors of the node error classes of
+	// solanago.ClassifyError.
+	nodeErrors = map[error]*types.Error{
+		solanago.ErrBlockhashNotFound:       ErrBlockhashNotFound,
+		solanago.ErrInsufficientFundsForFee: ErrInsufficientFundsForFee,
+		solanago.ErrAccountNotFound:         ErrAccountNotFound,
+		solanago.ErrSlotSkipped:             ErrSlotSkipped,
+		solanago.ErrNodeBehind:              ErrNodeBehind,
+		solanago.ErrRateLimited:             ErrRateLimited,
+		solanago.ErrInvalidParams:           ErrInvalidParams,
+		solanago.ErrTransactionFailed:       ErrTransactionFailed,
+	}
 )
 
 // wrapErr adds details to the types.Error provided. We use a function
@@ -151,3 +341,49 @@ func wrapErr(rErr *types.Error, err error) *types.Error {
 
 	return newErr
 }
+
+// wrapErrDetails is like wrapErr but attaches structured details.
+func wrapErrDetails(rErr *types.Error, details map[string]interface{}) *types.Error {
+	newErr := wrapErr(rErr, nil)
+	newErr.Details = details
+
+	return newErr
+}
+
+// nodeErr returns the error of the class of err, an error of the
+// node, or rErr if err is not classified.
+func nodeErr(rErr *types.Error, err error) *types.Error {
+	if classified, ok := nodeErrors[solanago.ClassifyError(err)]; ok {
+		rErr = classified
+	}
+
+	return rpcDetails(wrapErr(rErr, err), err)
+}
+
+// rpcDetails adds the JSON-RPC method, code and data of err to the
+// details of rErr.
+func rpcDetails(rErr *types.Error, err error) *types.Error {
+	var rpcErr *solanago.RPCError
+	if !errors.As(err, &rpcErr) {
+		return rErr
+	}
+	rErr.Details["code"] = rpcErr.Code
+	if len(rpcErr.Method) > 0 {
+		rErr.Details["method"] = rpcErr.Method
+	}
+	var data interface{}
+	if len(rpcErr.Data) > 0 && json.Unmarshal(rpcErr.Data, &data) == nil {
+		rErr.Details["data"] = data
+	}
+
+	return rErr
+}
+
+// unclearIntentErr returns ErrUnclearIntent with the indexes of the
+// offending operations.
+func unclearIntentErr(reason string, indices ...int64) *types.Error {
+	return wrapErrDetails(ErrUnclearIntent, map[string]interface{}{
+		"context": reason,
+		"indices": indices,
+	})
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/imerkle/rosetta-solana-go/logger"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+)
+
+// TestMain drops the logs of the requests without a logger of the
+// tests.
+func TestMain(m *testing.M) {
+	slog.SetDefault(logger.Discard())
+	os.Exit(m.Run())
+}
+
+// fakeRPC answers JSON-RPC requests with the result of the handler of
+// their method, or its error if it returns a *solanago.RPCError or
+// an httpStatus, and records the requests it served.
+type fakeRPC struct {
+	handlers map[string]func(params []interface{}) interface{}
+	requests []fakeRequest
+}
+
+// httpStatus is returned by a fakeRPC handler to fail the request
+// with the HTTP status.
+type httpStatus int
+
+type fakeRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// newFakeClient starts a fakeRPC server for the test and returns a
+// client of it.
+func newFakeClient(t *testing.T, handlers map[string]func(params []interface{}) interface{}) (*solanago.Client, *fakeRPC) {
+	f := &fakeRPC{handlers: handlers}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req fakeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.requests = append(f.requests, req)
+		res := map[string]interface{}{"jsonrpc": "2.0", "id": 1}
+		if h, ok := f.handlers[req.Method]; ok {
+			result := h(req.Params)
+			if status, ok := result.(httpStatus); ok {
+				w.WriteHeader(int(status))
+				return
+			}
+			if rpcErr, ok := result.(*solanago.RPCError); ok {
+				res["error"] = rpcErr
+			} else {
+				res["result"] = result
+			}
+		} else {
+			res["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(server.Close)
+
+	// Without retries the services see the first error of the node, and
+	// without health checks only the requests of the tests reach it.
+	client, err := solanago.NewClientWithConfig(solanago.ClientConfig{URLs: []string{server.URL}, HealthCheckInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, f
+}
+
+// rpcContext wraps value in the {context, value} result of the node.
+func rpcContext(slot uint64, value interface{}) interface{} {
+	return map[string]interface{}{
+		"context": map[string]interface{}{"slot": slot},
+		"value":   value,
+	}
+}
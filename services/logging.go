@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/logger"
+)
+
+// RequestIDHeader is the header of the id of a request. A request
+// without one is given a random id, returned in the header of its
+// response.
+const RequestIDHeader = "X-Request-ID"
+
+// LoggingMiddleware logs the requests served by next with l, and passes
+// them a logger with their request id, endpoint and network identifier
+// through their context.
+func LoggingMiddleware(l *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if len(id) == 0 {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		reqLogger := l.With("request_id", id, "endpoint", r.URL.Path)
+		if network := networkIdentifier(r); network != nil {
+			reqLogger = reqLogger.With("network", network)
+		}
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(logger.WithContext(r.Context(), reqLogger)))
+
+		fields := []interface{}{"method", r.Method, "status", rw.status, "duration", time.Since(start)}
+		switch {
+		case rw.status >= http.StatusBadRequest:
+			if rosettaErr := rw.rosettaError(); rosettaErr != nil {
+				fields = append(fields, "error", rosettaErr)
+			}
+			reqLogger.Warn("request failed", fields...)
+		case r.URL.Path == "/metrics":
+			reqLogger.Debug("request served", fields...)
+		default:
+			reqLogger.Info("request served", fields...)
+		}
+	})
+}
+
+// networkIdentifier returns the network identifier of the body of r,
+// which is left to be read again.
+func networkIdentifier(r *http.Request) *types.NetworkIdentifier {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	var request struct {
+		NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil
+	}
+	return request.NetworkIdentifier
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
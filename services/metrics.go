@@ -0,0 +1,169 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxErrorBody is how much of an error response is kept to read its
+// Rosetta error code.
+const maxErrorBody = 64 << 10
+
+// rosettaEndpoints are the endpoints of the Rosetta API. Requests to
+// other paths are counted as "other".
+var rosettaEndpoints = map[string]bool{
+	"/network/list":            true,
+	"/network/options":         true,
+	"/network/status":          true,
+	"/account/balance":         true,
+	"/account/coins":           true,
+	"/block":                   true,
+	"/block/transaction":       true,
+	"/mempool":                 true,
+	"/mempool/transaction":     true,
+	"/construction/derive":     true,
+	"/construction/preprocess": true,
+	"/construction/metadata":   true,
+	"/construction/payloads":   true,
+	"/construction/combine":    true,
+	"/construction/parse":      true,
+	"/construction/hash":       true,
+	"/construction/submit":     true,
+	"/call":                    true,
+	"/events/blocks":           true,
+	"/search/transactions":     true,
+}
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rosetta_requests_total",
+		Help: "Requests served, per endpoint and HTTP status.",
+	}, []string{"endpoint", "status"})
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rosetta_request_errors_total",
+		Help: "Requests that failed, per endpoint and Rosetta error code.",
+	}, []string{"endpoint", "code"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rosetta_request_duration_seconds",
+		Help:    "Latency of the requests, per endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	currentBlock = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "rosetta_current_block_index",
+		Help: "Index of the current block last reported by /network/status.",
+	})
+)
+
+// MetricsMiddleware records the requests served by next: their count
+// per HTTP status, their latency and the Rosetta error code of the
+// requests that fail.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := r.URL.Path
+		if !rosettaEndpoints[endpoint] {
+			endpoint = "other"
+		}
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		requestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		requests.WithLabelValues(endpoint, strconv.Itoa(rw.status)).Inc()
+		if rw.status >= http.StatusBadRequest {
+			code := "none"
+			if rosettaErr := rw.rosettaError(); rosettaErr != nil {
+				code = strconv.Itoa(int(rosettaErr.Code))
+			}
+			requestErrors.WithLabelValues(endpoint, code).Inc()
+		}
+	})
+}
+
+// responseRecorder keeps the status of a response, and the body of
+// an error response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if w.status >= http.StatusBadRequest && w.body.Len() < maxErrorBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// rosettaError returns the Rosetta error of an error response, or nil
+// if it is not one.
+func (w *responseRecorder) rosettaError() *types.Error {
+	var rosettaErr types.Error
+	if err := json.Unmarshal(w.body.Bytes(), &rosettaErr); err != nil || len(rosettaErr.Message) == 0 {
+		return nil
+	}
+	return &rosettaErr
+}
+
+// registerBlockCacheMetrics exposes the statistics of cache, replacing
+// the cache registered before.
+func registerBlockCacheMetrics(cache *blockCache) {
+	register := func(c prometheus.Collector) {
+		prometheus.Unregister(c)
+		prometheus.MustRegister(c)
+	}
+	counter := func(name string, help string, value func(BlockCacheStats) uint64) {
+		register(prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(cache.Stats()))
+		}))
+	}
+	gauge := func(name string, help string, value func(BlockCacheStats) int) {
+		register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(cache.Stats()))
+		}))
+	}
+	counter("rosetta_block_cache_hits_total", "Blocks served from the memory of the block cache.",
+		func(s BlockCacheStats) uint64 { return s.Hits })
+	counter("rosetta_block_cache_disk_hits_total", "Blocks served from the disk of the block cache.",
+		func(s BlockCacheStats) uint64 { return s.DiskHits })
+	counter("rosetta_block_cache_misses_total", "Blocks read from the node.",
+		func(s BlockCacheStats) uint64 { return s.Misses })
+	counter("rosetta_block_cache_evictions_total", "Blocks evicted from the memory of the block cache.",
+		func(s BlockCacheStats) uint64 { return s.Evictions })
+	counter("rosetta_block_cache_invalidations_total", "Unfinalized blocks invalidated in the block cache.",
+		func(s BlockCacheStats) uint64 { return s.Invalidations })
+	gauge("rosetta_block_cache_blocks", "Blocks in the memory of the block cache.",
+		func(s BlockCacheStats) int { return s.Blocks })
+	gauge("rosetta_block_cache_disk_blocks", "Blocks on the disk of the block cache.",
+		func(s BlockCacheStats) int { return s.DiskBlocks })
+}
@@ -81,17 +81,22 @@ func (s *NetworkAPIService) NetworkStatus(
 		return nil, ErrUnavailableOffline
 	}
 
-	currentBlock, currentTime, peers, genesisBlock, err := s.client.Status(ctx)
+	ctx, terr := withCommitment(ctx, request.Metadata)
+	if terr != nil {
+		return nil, terr
+	}
+	current, currentTime, peers, genesisBlock, err := s.client.Status(ctx)
 	if err != nil {
-		return nil, wrapErr(ErrGeth, err)
+		return nil, nodeErr(ErrGeth, err)
 	}
 
 	if currentTime < asserter.MinUnixEpoch {
 		return nil, ErrGethNotReady
 	}
+	currentBlock.Set(float64(current.Index))
 
 	return &types.NetworkStatusResponse{
-		CurrentBlockIdentifier: currentBlock,
+		CurrentBlockIdentifier: current,
 		CurrentBlockTimestamp:  currentTime,
 		GenesisBlockIdentifier: genesisBlock,
 		Peers:                  peers,
@@ -0,0 +1,279 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/portto/solana-go-sdk/common"
+	"github.com/portto/solana-go-sdk/sysprog"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+// placeholderNonceAccount stands in for the nonce account of a pooled
+// nonce before /construction/metadata allocated one.
+var placeholderNonceAccount = common.PublicKeyFromBytes(bytes.Repeat([]byte{1}, common.PublicKeyLength)).ToBase58()
+
+// noncePool hands out the durable nonce accounts of each authority to
+// one transaction at a time. The accounts of an authority are loaded
+// from the node on first use and again when none is free, so accounts
+// created with System__CreateNonceAccount join the pool.
+type noncePool struct {
+	client *solanago.Client
+	lease  time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	accounts map[string][]*pooledNonce
+}
+
+// pooledNonce is a nonce account of the pool. It is free when
+// allocated is zero. signature is the transaction using it once
+// submitted.
+type pooledNonce struct {
+	account   string
+	allocated time.Time
+	signature string
+}
+
+func newNoncePool(client *solanago.Client, lease time.Duration) *noncePool {
+	return &noncePool{
+		client:   client,
+		lease:    lease,
+		now:      time.Now,
+		accounts: make(map[string][]*pooledNonce),
+	}
+}
+
+// allocate returns a free nonce account of authority with its nonce.
+// fetch reads and checks a nonce account; accounts it rejects are
+// dropped from the pool. The pool is not locked while the node is
+// queried, so an account is only leased once it is fetched and still
+// free.
+func (p *noncePool) allocate(
+	ctx context.Context,
+	authority string,
+	fetch func(account string) (*solanago.ParsedAccountInfo, *types.Error),
+) (string, *solanago.ParsedAccountInfo, *types.Error) {
+	p.mu.Lock()
+	_, loaded := p.accounts[authority]
+	p.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if !loaded || attempt > 0 {
+			if terr := p.load(ctx, authority); terr != nil {
+				return "", nil, terr
+			}
+		}
+		if terr := p.reclaim(ctx, authority); terr != nil {
+			return "", nil, terr
+		}
+
+		for _, account := range p.free(authority) {
+			info, terr := fetch(account)
+			if terr != nil {
+				if !isNonceAccountErr(terr) {
+					return "", nil, terr
+				}
+				p.drop(authority, account)
+				continue
+			}
+			if p.acquire(account) {
+				return account, info, nil
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return "", nil, wrapErrDetails(ErrNoncePoolExhausted, map[string]interface{}{
+		"authority": authority,
+		"accounts":  len(p.accounts[authority]),
+		"context":   "create nonce accounts with System__CreateNonceAccount",
+	})
+}
+
+// free returns the accounts of authority that are not allocated.
+func (p *noncePool) free(authority string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var accounts []string
+	for _, v := range p.accounts[authority] {
+		if v.allocated.IsZero() {
+			accounts = append(accounts, v.account)
+		}
+	}
+	return accounts
+}
+
+// acquire allocates account if it is still free.
+func (p *noncePool) acquire(account string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v := p.find(account)
+	if v == nil || !v.allocated.IsZero() {
+		return false
+	}
+	v.allocated = p.now()
+	v.signature = ""
+	return true
+}
+
+// drop removes account from the pool of authority.
+func (p *noncePool) drop(authority string, account string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var kept []*pooledNonce
+	for _, v := range p.accounts[authority] {
+		if v.account != account {
+			kept = append(kept, v)
+		}
+	}
+	p.accounts[authority] = kept
+}
+
+// load adds the nonce accounts of authority the pool does not know yet.
+func (p *noncePool) load(ctx context.Context, authority string) *types.Error {
+	accounts, err := p.client.GetNonceAccounts(ctx, authority)
+	if err != nil {
+		return nodeErr(ErrGeth, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	known := make(map[string]bool)
+	for _, v := range p.accounts[authority] {
+		known[v.account] = true
+	}
+	pool := p.accounts[authority]
+	for _, v := range accounts {
+		if !known[v] {
+			pool = append(pool, &pooledNonce{account: v})
+		}
+	}
+	p.accounts[authority] = pool
+	return nil
+}
+
+// reclaim frees the accounts of authority whose transaction was
+// confirmed or failed, and those whose lease expired.
+func (p *noncePool) reclaim(ctx context.Context, authority string) *types.Error {
+	p.mu.Lock()
+	var pending []*pooledNonce
+	var signatures []string
+	for _, v := range p.accounts[authority] {
+		if v.allocated.IsZero() {
+			continue
+		}
+		if len(v.signature) > 0 {
+			pending = append(pending, v)
+			signatures = append(signatures, v.signature)
+		} else if p.now().Sub(v.allocated) > p.lease {
+			v.allocated = time.Time{}
+		}
+	}
+	p.mu.Unlock()
+	if len(signatures) == 0 {
+		return nil
+	}
+
+	statuses, err := p.client.GetSignatureStatuses(ctx, signatures)
+	if err != nil {
+		return nodeErr(ErrGeth, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, v := range pending {
+		// The account was released or used again while unlocked.
+		if v.signature != signatures[i] {
+			continue
+		}
+		var status *solanago.SignatureStatus
+		if i < len(statuses) {
+			status = statuses[i]
+		}
+		done := status != nil && (status.Err != nil ||
+			status.ConfirmationStatus == solanago.ConfirmedCommitment ||
+			status.ConfirmationStatus == solanago.FinalizedCommitment)
+		if done || (status == nil && p.now().Sub(v.allocated) > p.lease) {
+			v.allocated = time.Time{}
+			v.signature = ""
+		}
+	}
+	return nil
+}
+
+// submitted records the transaction using account. Its lease restarts
+// and the account is freed once the transaction is confirmed.
+func (p *noncePool) submitted(account string, signature string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v := p.find(account); v != nil {
+		v.allocated = p.now()
+		v.signature = signature
+	}
+}
+
+// release frees account.
+func (p *noncePool) release(account string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v := p.find(account); v != nil {
+		v.allocated = time.Time{}
+		v.signature = ""
+	}
+}
+
+func (p *noncePool) find(account string) *pooledNonce {
+	for _, pool := range p.accounts {
+		for _, v := range pool {
+			if v.account == account {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// isNonceAccountErr returns true if terr rejects a nonce account, as
+// opposed to an error of the node.
+func isNonceAccountErr(terr *types.Error) bool {
+	return terr.Code == ErrNonceAccountNotFound.Code ||
+		terr.Code == ErrNonceNotInitialized.Code ||
+		terr.Code == ErrNonceAuthorityMismatch.Code
+}
+
+// nonceAccount returns the nonce account tx advances, if it uses a
+// durable nonce.
+func nonceAccount(tx solPTypes.Transaction) (string, bool) {
+	message := tx.Message
+	if len(message.Instructions) == 0 {
+		return "", false
+	}
+	ins := message.Instructions[0]
+	if ins.ProgramIDIndex >= len(message.Accounts) ||
+		message.Accounts[ins.ProgramIDIndex] != common.SystemProgramID ||
+		len(ins.Data) < 4 || len(ins.Accounts) == 0 ||
+		sysprog.Instruction(ins.Data[0]) != sysprog.InstructionAdvanceNonceAccount {
+		return "", false
+	}
+	return message.Accounts[ins.Accounts[0]].ToBase58(), true
+}
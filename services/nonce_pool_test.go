@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/configuration"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+	"gotest.tools/assert"
+)
+
+func TestConstructionNoncePool(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	authority := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	nonces := []string{
+		solPTypes.NewAccount().PublicKey.ToBase58(),
+		solPTypes.NewAccount().PublicKey.ToBase58(),
+	}
+	confirmed := map[string]bool{}
+	client, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getProgramAccounts": func([]interface{}) interface{} {
+			return []interface{}{
+				map[string]interface{}{"pubkey": nonces[0]},
+				map[string]interface{}{"pubkey": nonces[1]},
+			}
+		},
+		"getAccountInfo": func(params []interface{}) interface{} {
+			return rpcContext(100, map[string]interface{}{
+				"lamports": 1447680,
+				"owner":    "11111111111111111111111111111111",
+				"data": map[string]interface{}{
+					"program": "nonce",
+					"parsed": map[string]interface{}{
+						"type": "initialized",
+						"info": map[string]interface{}{
+							"authority":     authority,
+							"blockhash":     "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+							"feeCalculator": map[string]interface{}{"lamportsPerSignature": "5000"},
+						},
+					},
+				},
+			})
+		},
+		"getSignatureStatuses": func(params []interface{}) interface{} {
+			var statuses []interface{}
+			for _, v := range params[0].([]interface{}) {
+				if confirmed[v.(string)] {
+					statuses = append(statuses, map[string]interface{}{"slot": 100, "confirmationStatus": "confirmed"})
+				} else {
+					statuses = append(statuses, nil)
+				}
+			}
+			return rpcContext(100, statuses)
+		},
+		"sendTransaction": func([]interface{}) interface{} {
+			return "sig1"
+		},
+	})
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Online}, client)
+	// The test polls the submitter itself.
+	s.submitter.running = true
+	options := map[string]interface{}{
+		"with_nonce": map[string]interface{}{"authority": authority},
+	}
+	allocate := func() (string, *types.Error) {
+		res, terr := s.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{Options: options})
+		if terr != nil {
+			return "", terr
+		}
+		withNonce, _ := solanago.GetWithNonce(res.Metadata)
+		return withNonce.Account, nil
+	}
+
+	first, terr := allocate()
+	assert.Assert(t, terr == nil)
+	second, terr := allocate()
+	assert.Assert(t, terr == nil)
+	assert.DeepEqual(t, nonces, []string{first, second})
+	_, terr = allocate()
+	assert.Equal(t, ErrNoncePoolExhausted.Code, terr.Code)
+
+	// Submitting a transaction advancing the first nonce frees it once
+	// confirmed.
+	metaRes, terr := s.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{Options: map[string]interface{}{
+		"with_nonce": map[string]interface{}{"account": first, "authority": authority},
+	}})
+	assert.Assert(t, terr == nil)
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: authority},
+			Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "1000", Currency: cSol},
+		}},
+		Metadata: metaRes.Metadata,
+	})
+	assert.Assert(t, terr == nil)
+	_, terr = s.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{SignedTransaction: payRes.UnsignedTransaction})
+	assert.Assert(t, terr == nil)
+	_, terr = allocate()
+	assert.Equal(t, ErrNoncePoolExhausted.Code, terr.Code)
+	confirmed["sig1"] = true
+	account, terr := allocate()
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, first, account)
+
+	// Nonces that are not submitted are freed when their lease expires.
+	s.noncePool.now = func() time.Time { return time.Now().Add(2 * configuration.DefaultNonceLease) }
+	first, terr = allocate()
+	assert.Assert(t, terr == nil)
+	second, terr = allocate()
+	assert.Assert(t, terr == nil)
+	assert.DeepEqual(t, nonces, []string{first, second})
+}
+
+func TestNoncePoolAllocateUnlocked(t *testing.T) {
+	ctx := context.Background()
+	authority := solPTypes.NewAccount().PublicKey.ToBase58()
+	nonces := []string{
+		solPTypes.NewAccount().PublicKey.ToBase58(),
+		solPTypes.NewAccount().PublicKey.ToBase58(),
+	}
+	client, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getProgramAccounts": func([]interface{}) interface{} {
+			return []interface{}{
+				map[string]interface{}{"pubkey": nonces[0]},
+				map[string]interface{}{"pubkey": nonces[1]},
+			}
+		},
+	})
+	p := newNoncePool(client, configuration.DefaultNonceLease)
+
+	// The pool is not locked while a nonce is fetched, so another
+	// request can take the same account meanwhile.
+	var nested string
+	var fetch func(account string) (*solanago.ParsedAccountInfo, *types.Error)
+	fetch = func(account string) (*solanago.ParsedAccountInfo, *types.Error) {
+		assert.Assert(t, p.mu.TryLock())
+		p.mu.Unlock()
+		if len(nested) == 0 {
+			nested = "-"
+			account, _, terr := p.allocate(ctx, authority, fetch)
+			assert.Assert(t, terr == nil)
+			nested = account
+		}
+		return &solanago.ParsedAccountInfo{}, nil
+	}
+	account, _, terr := p.allocate(ctx, authority, fetch)
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, nonces[0], nested)
+	assert.Equal(t, nonces[1], account)
+}
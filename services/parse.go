@@ -0,0 +1,670 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/portto/solana-go-sdk/common"
+	"github.com/portto/solana-go-sdk/sysprog"
+	"github.com/portto/solana-go-sdk/tokenprog"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+var (
+	systemProgram = common.SystemProgramID.ToBase58()
+	tokenProgram  = common.TokenProgramID.ToBase58()
+	assocProgram  = common.SPLAssociatedTokenAccountProgramID.ToBase58()
+)
+
+// decodedOp is the operation one or more instructions are built from,
+// merged the way matchOperations merges the operations of a request: a
+// transfer has the sender account, the receiver amount and the source
+// and destination metadata.
+type decodedOp struct {
+	op     *types.Operation
+	paired bool
+	// n is the number of instructions of op.
+	n int
+}
+
+// instruction is a parsed instruction with its info decoded with
+// json.Number numbers.
+type instruction struct {
+	programID string
+	typ       string
+	info      map[string]interface{}
+}
+
+// str returns the string field k of the info of ins.
+func (ins instruction) str(k string) string {
+	s, _ := ins.info[k].(string)
+	return s
+}
+
+// num returns the number field k of the info of ins.
+func (ins instruction) num(k string) json.Number {
+	n, _ := ins.info[k].(json.Number)
+	return n
+}
+
+// is returns true if ins is the instruction typ of programID.
+func (ins instruction) is(programID string, typ string) bool {
+	return ins.programID == programID && ins.typ == typ
+}
+
+// authority returns the authority of a token instruction, named field
+// or, for a multisig authority, multisigField, and the multisig
+// signers.
+func (ins instruction) authority(field string, multisigField string) (string, []interface{}) {
+	if multisig := ins.str(multisigField); len(multisig) > 0 {
+		signers, _ := ins.info["signers"].([]interface{})
+		return multisig, signers
+	}
+	return ins.str(field), nil
+}
+
+// constructionOperations decodes the instructions of tx into the
+// operations ConstructionPayloads builds tx from: transfers are pairs
+// whose accounts and amounts carry the source, destination and amount,
+// and the metadata holds the payloads fields that differ from their
+// defaults. It returns an error for instructions no operation builds.
+func constructionOperations(tx solPTypes.Transaction, parsed solPTypes.ParsedTransaction) ([]*types.Operation, error) {
+	var instructions []instruction
+	for _, v := range parsed.Message.Instructions {
+		ins := instruction{programID: v.ProgramID}
+		if v.Parsed != nil {
+			ins.typ = v.Parsed.InstructionType
+			b, _ := json.Marshal(v.Parsed.Info)
+			d := json.NewDecoder(bytes.NewReader(b))
+			d.UseNumber()
+			d.Decode(&ins.info)
+		}
+		instructions = append(instructions, ins)
+	}
+	original := tx.Message.DecompileInstructions()
+
+	var ops []*types.Operation
+	for i := 0; i < len(instructions); {
+		d, err := decodeOperation(instructions[i:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: instruction %d", err, i)
+		}
+		if i+d.n < len(instructions) && instructions[i+d.n].typ == solanago.MemoKey {
+			d.op.Metadata[solanago.MemoKey] = instructions[i+d.n].str(solanago.MemoKey)
+			d.n++
+		}
+		ins, terr := operationsToInstructions([]*types.Operation{d.op}, nil)
+		if terr != nil || len(ins) != d.n || !matchInstructions(ins, original[i:]) {
+			return nil, fmt.Errorf("%s does not build instructions %d to %d", d.op.Type, i, i+d.n-1)
+		}
+		i += d.n
+		ops = append(ops, d.operations()...)
+	}
+	for i, op := range ops {
+		op.OperationIdentifier = &types.OperationIdentifier{Index: int64(i)}
+	}
+	return ops, nil
+}
+
+// decodeOperation decodes the operation of the first instructions of
+// ins. The operations built from several instructions are decoded
+// before the operations of their single instructions.
+func decodeOperation(ins []instruction) (*decodedOp, error) {
+	for _, decode := range []func([]instruction) *decodedOp{
+		decodeCreateNonceAccount,
+		decodeCreateToken,
+		decodeCreateTokenAccount,
+		decodeWrapSol,
+		decodeTokenSweep,
+		decodeTransferWithSystem,
+		decodeTransferNew,
+	} {
+		if d := decode(ins); d != nil {
+			return d, nil
+		}
+	}
+	switch ins[0].programID {
+	case systemProgram:
+		return decodeSystem(ins[0])
+	case tokenProgram:
+		return decodeToken(ins[0])
+	case assocProgram:
+		if ins[0].typ == "create" {
+			return single(solanago.SplAssociatedTokenAccount__Create, ins[0].str("source"), map[string]interface{}{
+				"wallet": ins[0].str("wallet"),
+				"mint":   ins[0].str("mint"),
+			}), nil
+		}
+	}
+	if len(ins[0].typ) == 0 {
+		return nil, fmt.Errorf("unknown instruction of program %s", ins[0].programID)
+	}
+	return nil, fmt.Errorf("no operation builds a %s instruction", ins[0].typ)
+}
+
+// decodeSystem decodes the operation of a system instruction.
+func decodeSystem(ins instruction) (*decodedOp, error) {
+	switch ins.typ {
+	case "transfer":
+		return pair(solanago.System__Transfer, ins.str("source"), ins.str("destination"), ins.num("lamports"), solCurrency(), nil), nil
+	case "createAccount":
+		return pair(solanago.System__CreateAccount, ins.str("source"), ins.str("newAccount"), ins.num("lamports"), solCurrency(), withDefaults(map[string]interface{}{
+			"space": ins.num("space"),
+			"owner": ins.str("owner"),
+		}, map[string]interface{}{"owner": tokenProgram})), nil
+	case "assign":
+		return single(solanago.System__Assign, ins.str("account"), withDefaults(map[string]interface{}{
+			"owner": ins.str("owner"),
+		}, map[string]interface{}{"owner": tokenProgram})), nil
+	case "allocate":
+		return single(solanago.System__Allocate, ins.str("account"), withDefaults(map[string]interface{}{
+			"space": ins.num("space"),
+		}, nil)), nil
+	case "advanceNonce":
+		return single(solanago.System__AdvanceNonce, ins.str("nonceAuthority"), map[string]interface{}{
+			"destination": ins.str("nonceAccount"),
+		}), nil
+	case "withdrawFromNonce":
+		return pair(solanago.System__WithdrawFromNonce, ins.str("nonceAccount"), ins.str("destination"), ins.num("lamports"), solCurrency(), withDefaults(map[string]interface{}{
+			"authority": ins.str("nonceAuthority"),
+		}, map[string]interface{}{"authority": ins.str("nonceAccount")})), nil
+	case "authorizeNonce":
+		return single(solanago.System__AuthorizeNonce, ins.str("nonceAuthority"), map[string]interface{}{
+			"destination":   ins.str("nonceAccount"),
+			"new_authority": ins.str("newAuthorized"),
+		}), nil
+	case "createAccountWithSeed":
+		return pair(solanago.System__CreateAccountWithSeed, ins.str("source"), ins.str("newAccount"), ins.num("lamports"), solCurrency(), withDefaults(map[string]interface{}{
+			"base":  ins.str("base"),
+			"seed":  ins.info["seed"],
+			"space": ins.num("space"),
+			"owner": ins.str("owner"),
+		}, map[string]interface{}{"base": ins.str("source"), "owner": systemProgram})), nil
+	case "transferWithSeed":
+		return pair(solanago.System__TransferWithSeed, ins.str("source"), ins.str("destination"), ins.num("lamports"), solCurrency(), withDefaults(map[string]interface{}{
+			"base":  ins.str("sourceBase"),
+			"seed":  ins.info["sourceSeed"],
+			"owner": ins.str("sourceOwner"),
+		}, map[string]interface{}{"owner": systemProgram})), nil
+	case "assignWithSeed":
+		return single(solanago.System__AssignWithSeed, ins.str("account"), withDefaults(map[string]interface{}{
+			"base":  ins.str("base"),
+			"seed":  ins.info["seed"],
+			"owner": ins.str("owner"),
+		}, map[string]interface{}{"owner": systemProgram})), nil
+	case "allocateWithSeed":
+		return single(solanago.System__AllocateWithSeed, ins.str("account"), withDefaults(map[string]interface{}{
+			"base":  ins.str("base"),
+			"seed":  ins.info["seed"],
+			"space": ins.num("space"),
+			"owner": ins.str("owner"),
+		}, map[string]interface{}{"owner": systemProgram})), nil
+	}
+	return nil, fmt.Errorf("no operation builds a %s instruction", ins.typ)
+}
+
+// decodeToken decodes the operation of a token instruction. The
+// unchecked instructions do not record the decimals of their mint, so
+// their amount is metadata like the amount of an approval.
+func decodeToken(ins instruction) (*decodedOp, error) {
+	var d *decodedOp
+	var authority string
+	var signers []interface{}
+	switch ins.typ {
+	case "transfer":
+		authority, signers = ins.authority("authority", "multisigAuthority")
+		d = single(solanago.SplToken__Transfer, ins.str("source"), map[string]interface{}{
+			"destination": ins.str("destination"),
+			"amount":      ins.num("amount"),
+		})
+	case "transferChecked":
+		authority, signers = ins.authority("authority", "multisigAuthority")
+		amount, currency := tokenAmount(ins)
+		d = pair(solanago.SplToken__TransferChecked, ins.str("source"), ins.str("destination"), amount, currency, map[string]interface{}{})
+	case "initializeMint":
+		return single(solanago.SplToken__InitializeMint, ins.str("mintAuthority"), withDefaults(map[string]interface{}{
+			"mint":      ins.str("mint"),
+			"decimals":  ins.num("decimals"),
+			"authority": ins.str("freezeAuthority"),
+		}, map[string]interface{}{"authority": ins.str("mintAuthority")})), nil
+	case "initializeAccount":
+		return single(solanago.SplToken__InitializeAccount, ins.str("owner"), map[string]interface{}{
+			"destination": ins.str("account"),
+			"mint":        ins.str("mint"),
+		}), nil
+	case "initializeMultisig":
+		return single(solanago.SplToken__InitializeMultisig, ins.str("multisig"), map[string]interface{}{
+			"signers": ins.info["signers"],
+			"m":       ins.num("m"),
+		}), nil
+	case "approve":
+		authority, signers = ins.authority("owner", "multisigOwner")
+		d = single(solanago.SplToken__Approve, ins.str("source"), map[string]interface{}{
+			"destination": ins.str("delegate"),
+			"amount":      ins.num("amount"),
+		})
+	case "revoke":
+		authority, signers = ins.authority("owner", "multisigOwner")
+		d = single(solanago.SplToken__Revoke, ins.str("source"), map[string]interface{}{})
+	case "mintTo":
+		authority, signers = ins.authority("mintAuthority", "multisigMintAuthority")
+		d = single(solanago.SplToken__MintTo, ins.str("account"), map[string]interface{}{
+			"mint":   ins.str("mint"),
+			"amount": ins.num("amount"),
+		})
+	case "mintToChecked":
+		authority, signers = ins.authority("mintAuthority", "multisigMintAuthority")
+		amount, currency := tokenAmount(ins)
+		d = single(solanago.SplToken__MintToChecked, ins.str("account"), map[string]interface{}{})
+		d.op.Amount = &types.Amount{Value: amount.String(), Currency: currency}
+	case "burn":
+		authority, signers = ins.authority("authority", "multisigAuthority")
+		d = single(solanago.SplToken__Burn, ins.str("account"), map[string]interface{}{
+			"mint":   ins.str("mint"),
+			"amount": ins.num("amount"),
+		})
+	case "burnChecked":
+		authority, signers = ins.authority("authority", "multisigAuthority")
+		amount, currency := tokenAmount(ins)
+		d = single(solanago.SplToken__BurnChecked, ins.str("account"), map[string]interface{}{})
+		d.op.Amount = &types.Amount{Value: "-" + amount.String(), Currency: currency}
+	case "closeAccount":
+		authority, signers = ins.authority("owner", "multisigOwner")
+		account, destination := ins.str("account"), ins.str("destination")
+		if account == associatedAccount(destination, solanago.NativeMint) {
+			d = single(solanago.SplToken__UnwrapSol, destination, map[string]interface{}{})
+		} else {
+			d = single(solanago.SplToken__CloseAccount, account, map[string]interface{}{
+				"destination": destination,
+			})
+		}
+	case "freezeAccount", "thawAccount":
+		opType := solanago.SplToken__FreezeAccount
+		if ins.typ == "thawAccount" {
+			opType = solanago.SplToken__ThawAccount
+		}
+		authority, signers = ins.authority("freezeAuthority", "multisigFreezeAuthority")
+		d = single(opType, ins.str("account"), map[string]interface{}{
+			"mint": ins.str("mint"),
+		})
+	case "setAuthority":
+		authority, signers = ins.authority("authority", "multisigAuthority")
+		owned := ins.str("mint")
+		if len(owned) == 0 {
+			owned = ins.str("account")
+		}
+		d = single(solanago.SplToken__SetAuthority, owned, map[string]interface{}{
+			"authority_type": ins.str("authorityType"),
+		})
+		if newAuthority := ins.str("newAuthority"); len(newAuthority) > 0 {
+			d.op.Metadata["new_authority"] = newAuthority
+		}
+	case "syncNative":
+		return single(solanago.SplToken__SyncNative, ins.str("account"), map[string]interface{}{}), nil
+	default:
+		return nil, fmt.Errorf("no operation builds a %s instruction", ins.typ)
+	}
+	setAuthority(d, authority, signers)
+	return d, nil
+}
+
+// decodeCreateNonceAccount decodes the creation of a nonce account
+// followed by its initialization.
+func decodeCreateNonceAccount(ins []instruction) *decodedOp {
+	if len(ins) < 2 || !ins[0].is(systemProgram, "createAccount") || !ins[1].is(systemProgram, "initializeNonce") ||
+		ins[0].str("owner") != systemProgram || ins[0].num("space") != numString(sysprog.NonceAccountSize) ||
+		ins[1].str("nonceAccount") != ins[0].str("newAccount") {
+		return nil
+	}
+	source := ins[0].str("source")
+	d := single(solanago.System__CreateNonceAccount, source, withDefaults(map[string]interface{}{
+		"destination": ins[0].str("newAccount"),
+		"lamports":    ins[0].num("lamports"),
+		"authority":   ins[1].str("nonceAuthority"),
+	}, map[string]interface{}{"authority": source}))
+	d.n = 2
+	return d
+}
+
+// decodeCreateToken decodes the creation of a mint account followed by
+// its initialization with its funder as mint authority.
+func decodeCreateToken(ins []instruction) *decodedOp {
+	if len(ins) < 2 || !ins[0].is(systemProgram, "createAccount") || !ins[1].is(tokenProgram, "initializeMint") ||
+		ins[0].str("owner") != tokenProgram || ins[0].num("space") != numString(tokenprog.MintAccountSize) ||
+		ins[1].str("mint") != ins[0].str("newAccount") || ins[1].str("mintAuthority") != ins[0].str("source") {
+		return nil
+	}
+	source := ins[0].str("source")
+	d := single(solanago.SplToken__CreateToken, source, withDefaults(map[string]interface{}{
+		"mint":      ins[1].str("mint"),
+		"amount":    ins[0].num("lamports"),
+		"decimals":  ins[1].num("decimals"),
+		"authority": ins[1].str("freezeAuthority"),
+	}, map[string]interface{}{"authority": source}))
+	d.n = 2
+	return d
+}
+
+// decodeCreateTokenAccount decodes the creation of a token account
+// followed by its initialization.
+func decodeCreateTokenAccount(ins []instruction) *decodedOp {
+	if len(ins) < 2 || !ins[0].is(systemProgram, "createAccount") || !ins[1].is(tokenProgram, "initializeAccount") ||
+		ins[0].str("owner") != tokenProgram || ins[0].num("space") != numString(tokenprog.TokenAccountSize) ||
+		ins[1].str("account") != ins[0].str("newAccount") {
+		return nil
+	}
+	source := ins[0].str("source")
+	d := single(solanago.SplToken__CreateAccount, source, withDefaults(map[string]interface{}{
+		"destination": ins[1].str("account"),
+		"mint":        ins[1].str("mint"),
+		"amount":      ins[0].num("lamports"),
+		"authority":   ins[1].str("owner"),
+	}, map[string]interface{}{"authority": source}))
+	d.n = 2
+	return d
+}
+
+// decodeWrapSol decodes a transfer of lamports into a native token
+// account followed by its sync, after the creation of the associated
+// account of the sender if it is the account wrapped into.
+func decodeWrapSol(ins []instruction) *decodedOp {
+	var funder string
+	var created string
+	if len(ins) > 0 && ins[0].is(assocProgram, "create") && ins[0].str("mint") == solanago.NativeMint {
+		funder, created = ins[0].str("source"), ins[0].str("account")
+		ins = ins[1:]
+	}
+	if len(ins) < 2 || !ins[0].is(systemProgram, "transfer") || !ins[1].is(tokenProgram, "syncNative") ||
+		ins[1].str("account") != ins[0].str("destination") {
+		return nil
+	}
+	source, account := ins[0].str("source"), ins[0].str("destination")
+	meta := map[string]interface{}{}
+	n := 2
+	switch {
+	case len(created) == 0:
+		meta["destination_token"] = account
+	case created == account && account == associatedAccount(source, solanago.NativeMint):
+		meta = withDefaults(map[string]interface{}{"authority": funder}, map[string]interface{}{"authority": source})
+		n = 3
+	default:
+		return nil
+	}
+	d := single(solanago.SplToken__WrapSol, source, meta)
+	d.op.Amount = &types.Amount{Value: "-" + ins[0].num("lamports").String(), Currency: solCurrency()}
+	d.n = n
+	return d
+}
+
+// decodeTokenSweep decodes a checked transfer out of a token account
+// followed by the closing of that account to its owner, after the
+// creation of the associated account of the receiver if it is the
+// account transferred to. Closing the account needs it to be empty, so
+// the transfer sends the whole balance.
+func decodeTokenSweep(ins []instruction) *decodedOp {
+	var funder, wallet, created string
+	n := 2
+	if len(ins) > 0 && ins[0].is(assocProgram, "create") {
+		funder, wallet, created = ins[0].str("source"), ins[0].str("wallet"), ins[0].str("account")
+		ins = ins[1:]
+		n = 3
+	}
+	if len(ins) < 2 || !ins[0].is(tokenProgram, "transferChecked") || !ins[1].is(tokenProgram, "closeAccount") {
+		return nil
+	}
+	authority, signers := ins[0].authority("authority", "multisigAuthority")
+	closeAuthority, closeSigners := ins[1].authority("owner", "multisigOwner")
+	source, mint := ins[0].str("source"), ins[0].str("mint")
+	owner := ins[1].str("destination")
+	if ins[1].str("account") != source || closeAuthority != authority || !equalSigners(signers, closeSigners) {
+		return nil
+	}
+	meta := map[string]interface{}{"mint": mint}
+	switch destination := ins[0].str("destination"); {
+	case len(created) == 0:
+		meta["destination_token"] = destination
+	case created == destination && funder == funderOf(authority, signers):
+		meta["destination"] = wallet
+	default:
+		return nil
+	}
+	if source != associatedAccount(owner, mint) {
+		meta["source_token"] = source
+	}
+	// The amount and decimals come from the sweeps of the payloads
+	// metadata and are left out of the operation.
+	amount, currency := tokenAmount(ins[0])
+	meta["amount"] = amount
+	meta["decimals"] = currency.Decimals
+	d := single(solanago.SplToken__Sweep, owner, meta)
+	setAuthority(d, authority, signers)
+	d.n = n
+	return d
+}
+
+// decodeTransferWithSystem decodes the creation of the associated
+// accounts of a sender and a receiver followed by a checked transfer
+// between them.
+func decodeTransferWithSystem(ins []instruction) *decodedOp {
+	if len(ins) < 3 || !ins[0].is(assocProgram, "create") || !ins[1].is(assocProgram, "create") ||
+		!ins[2].is(tokenProgram, "transferChecked") {
+		return nil
+	}
+	authority, signers := ins[2].authority("authority", "multisigAuthority")
+	funder := funderOf(authority, signers)
+	mint := ins[2].str("mint")
+	for i, account := range []string{ins[2].str("source"), ins[2].str("destination")} {
+		if ins[i].str("account") != account || ins[i].str("mint") != mint || ins[i].str("source") != funder {
+			return nil
+		}
+	}
+	sender := ins[0].str("wallet")
+	amount, currency := tokenAmount(ins[2])
+	d := pair(solanago.SplToken__TransferWithSystem, sender, ins[1].str("wallet"), amount, currency, map[string]interface{}{})
+	setAuthority(d, authority, signers)
+	d.n = 3
+	return d
+}
+
+// decodeTransferNew decodes the creation of the associated account of a
+// receiver followed by a checked transfer to it.
+func decodeTransferNew(ins []instruction) *decodedOp {
+	if len(ins) < 2 || !ins[0].is(assocProgram, "create") || !ins[1].is(tokenProgram, "transferChecked") {
+		return nil
+	}
+	authority, signers := ins[1].authority("authority", "multisigAuthority")
+	if ins[0].str("account") != ins[1].str("destination") || ins[0].str("mint") != ins[1].str("mint") ||
+		ins[0].str("source") != funderOf(authority, signers) {
+		return nil
+	}
+	amount, currency := tokenAmount(ins[1])
+	d := pair(solanago.SplToken__TransferNew, ins[1].str("source"), ins[0].str("wallet"), amount, currency, map[string]interface{}{})
+	setAuthority(d, authority, signers)
+	d.n = 2
+	return d
+}
+
+// single returns the operation of one instruction on account.
+func single(opType string, account string, meta map[string]interface{}) *decodedOp {
+	return &decodedOp{
+		op: &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: account},
+			Metadata:            meta,
+		},
+		n: 1,
+	}
+}
+
+// pair returns the transfer of amount from source to destination of one
+// instruction.
+func pair(
+	opType string,
+	source string,
+	destination string,
+	amount json.Number,
+	currency *types.Currency,
+	meta map[string]interface{},
+) *decodedOp {
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	meta["source"] = source
+	meta["destination"] = destination
+	return &decodedOp{
+		op: &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: source},
+			Amount:              &types.Amount{Value: amount.String(), Currency: currency},
+			Metadata:            meta,
+		},
+		paired: true,
+		n:      1,
+	}
+}
+
+// withDefaults returns meta without the fields that are empty, zero or
+// equal to their default in defaults.
+func withDefaults(meta map[string]interface{}, defaults map[string]interface{}) map[string]interface{} {
+	for k, v := range meta {
+		if v == nil || v == "" || v == json.Number("") || v == json.Number("0") || v == defaults[k] {
+			delete(meta, k)
+		}
+	}
+	return meta
+}
+
+// setAuthority records the authority of a token operation if it is not
+// the operation account, and the signers of a multisig authority.
+func setAuthority(d *decodedOp, authority string, signers []interface{}) {
+	if authority != d.op.Account.Address {
+		d.op.Metadata["authority"] = authority
+	}
+	if len(signers) > 0 {
+		d.op.Metadata["signers"] = signers
+	}
+}
+
+// funderOf returns the account paying for the accounts an operation
+// creates: its authority, or the first signer of a multisig authority.
+func funderOf(authority string, signers []interface{}) string {
+	if len(signers) > 0 {
+		s, _ := signers[0].(string)
+		return s
+	}
+	return authority
+}
+
+func equalSigners(a []interface{}, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenAmount returns the amount of a checked token instruction and the
+// currency of its mint.
+func tokenAmount(ins instruction) (json.Number, *types.Currency) {
+	tokenAmount, _ := ins.info["tokenAmount"].(map[string]interface{})
+	amount, _ := tokenAmount["amount"].(string)
+	decimals, _ := tokenAmount["decimals"].(json.Number)
+	d, _ := decimals.Int64()
+	return json.Number(amount), &types.Currency{Symbol: ins.str("mint"), Decimals: int32(d)}
+}
+
+func solCurrency() *types.Currency {
+	return &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+}
+
+func numString(n int) json.Number {
+	return json.Number(fmt.Sprint(n))
+}
+
+// associatedAccount returns the associated token account of wallet for
+// mint.
+func associatedAccount(wallet string, mint string) string {
+	account, _, _ := common.FindAssociatedTokenAddress(common.PublicKeyFromString(wallet), common.PublicKeyFromString(mint))
+	return account.ToBase58()
+}
+
+// operations returns the operations of d as a request passes them to
+// ConstructionPayloads.
+func (d *decodedOp) operations() []*types.Operation {
+	op := d.op
+	meta := op.Metadata
+	if op.Type == solanago.SplToken__Sweep {
+		delete(meta, "amount")
+		delete(meta, "decimals")
+	}
+	if !d.paired {
+		if len(meta) == 0 {
+			op.Metadata = nil
+		}
+		return []*types.Operation{op}
+	}
+	destination, _ := meta["destination"].(string)
+	delete(meta, "source")
+	delete(meta, "destination")
+	if len(meta) == 0 {
+		meta = nil
+	}
+	return []*types.Operation{{
+		Type:    op.Type,
+		Account: op.Account,
+		Amount: &types.Amount{
+			Value:    "-" + strings.TrimPrefix(op.Amount.Value, "-"),
+			Currency: op.Amount.Currency,
+		},
+		Metadata: meta,
+	}, {
+		Type:    op.Type,
+		Account: &types.AccountIdentifier{Address: destination},
+		Amount:  op.Amount,
+	}}
+}
+
+// matchInstructions returns true if ins are the first instructions of
+// original. The signer and writable flags of decompiled instructions
+// are those of the message and are not compared.
+func matchInstructions(ins []solPTypes.Instruction, original []solPTypes.Instruction) bool {
+	if len(ins) > len(original) {
+		return false
+	}
+	for i, v := range ins {
+		o := original[i]
+		if v.ProgramID != o.ProgramID || !bytes.Equal(v.Data, o.Data) || len(v.Accounts) != len(o.Accounts) {
+			return false
+		}
+		for j := range v.Accounts {
+			if v.Accounts[j].PubKey != o.Accounts[j].PubKey {
+				return false
+			}
+		}
+	}
+	return true
+}
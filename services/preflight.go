@@ -0,0 +1,309 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	ss "github.com/portto/solana-go-sdk/client"
+	"github.com/portto/solana-go-sdk/common"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+// getPreflight collects the balances and recipient account types
+// /construction/metadata must check for the merged operations.
+// feePayer defaults to the first signer of instructions.
+func getPreflight(
+	merged []*types.Operation,
+	instructions []solPTypes.Instruction,
+	feePayer string,
+) (solanago.Preflight, *types.Error) {
+	signers := solPTypes.GetUniqueSigners(instructions)
+	if len(signers) == 0 && len(feePayer) == 0 {
+		return solanago.Preflight{}, wrapErr(ErrUnclearIntent, fmt.Errorf("no signers"))
+	}
+	if len(feePayer) == 0 {
+		feePayer = signers[0]
+	} else if !solanago.Contains(signers, feePayer) {
+		signers = append([]string{feePayer}, signers...)
+	}
+	preflight := solanago.Preflight{
+		FeePayer: feePayer,
+		Signers:  signers,
+	}
+
+	balanceIndex := make(map[string]int)
+	for _, op := range merged {
+		if op.Amount == nil || op.Account == nil {
+			continue
+		}
+		if !solanago.IsBalanceChanging(op.Type) && op.Type != solanago.SplToken__WrapSol {
+			continue
+		}
+		mint := ""
+		if op.Amount.Currency.Symbol != solanago.Symbol {
+			mint = op.Amount.Currency.Symbol
+		}
+		amount := solanago.ValueToBaseAmount(op.Amount.Value)
+		key := op.Account.Address + mint
+		if i, ok := balanceIndex[key]; ok {
+			preflight.Balances[i].Amount += amount
+		} else {
+			balanceIndex[key] = len(preflight.Balances)
+			preflight.Balances = append(preflight.Balances, solanago.BalanceCheck{
+				Account: op.Account.Address,
+				Mint:    mint,
+				Amount:  amount,
+			})
+		}
+
+		destination, _ := op.Metadata["destination"].(string)
+		if destination == "" {
+			continue
+		}
+		switch op.Type {
+		case solanago.SplToken__Transfer, solanago.SplToken__TransferChecked:
+			preflight.Recipients = append(preflight.Recipients, solanago.RecipientCheck{
+				Account: destination,
+				Mint:    mint,
+				Kind:    solanago.RecipientTokenAccount,
+			})
+		case solanago.SplToken__TransferNew, solanago.SplToken__TransferWithSystem:
+			preflight.Recipients = append(preflight.Recipients, solanago.RecipientCheck{
+				Account: destination,
+				Mint:    mint,
+				Kind:    solanago.RecipientWallet,
+			})
+		}
+	}
+	return preflight, nil
+}
+
+// getNonce fetches the durable nonce account and checks that it is
+// initialized and controlled by the requested authority.
+func (s *ConstructionAPIService) getNonce(
+	ctx context.Context,
+	withNonce solanago.WithNonce,
+) (*solanago.ParsedAccountInfo, *types.Error) {
+	acc, err := s.client.GetAccountInfo(ctx, withNonce.Account)
+	if err != nil {
+		return nil, nodeErr(ErrGeth, err)
+	}
+	if acc == nil {
+		return nil, wrapErrDetails(ErrNonceAccountNotFound, map[string]interface{}{
+			"account": withNonce.Account,
+		})
+	}
+	if acc.Owner != common.SystemProgramID.ToBase58() ||
+		acc.Data.Program != "nonce" ||
+		acc.Data.Parsed.Type != "initialized" {
+		return nil, wrapErrDetails(ErrNonceNotInitialized, map[string]interface{}{
+			"account": withNonce.Account,
+			"owner":   acc.Owner,
+			"state":   acc.Data.Parsed.Type,
+		})
+	}
+	nonce := acc.Data.Parsed.Info
+	if len(withNonce.Authority) > 0 && withNonce.Authority != nonce.Authority {
+		return nil, wrapErrDetails(ErrNonceAuthorityMismatch, map[string]interface{}{
+			"account":   withNonce.Account,
+			"authority": nonce.Authority,
+			"requested": withNonce.Authority,
+		})
+	}
+	return &nonce, nil
+}
+
+// transactionFee returns the fee of the transaction signed by the
+// preflight signers and the nonce authority.
+func transactionFee(
+	preflight solanago.Preflight,
+	fee ss.FeeCalculator,
+	nonceAuthority string,
+) uint64 {
+	signers := preflight.Signers
+	if len(nonceAuthority) > 0 && !solanago.Contains(signers, nonceAuthority) {
+		signers = append(signers, nonceAuthority)
+	}
+	return fee.LamportsPerSignature * uint64(len(signers))
+}
+
+// checkPreflight verifies that every sender can cover its amounts, that
+// the fee payer can also cover the fee and that every receiver is the
+// kind of account its transfer expects.
+func (s *ConstructionAPIService) checkPreflight(
+	ctx context.Context,
+	preflight solanago.Preflight,
+	fee ss.FeeCalculator,
+	nonceAuthority string,
+) *types.Error {
+	totalFee := transactionFee(preflight, fee, nonceAuthority)
+
+	// Copy the balances so that adding the fee leaves preflight intact
+	// for the sweeps resolved after the checks.
+	balances := append([]solanago.BalanceCheck(nil), preflight.Balances...)
+	hasFeePayer := false
+	for i, b := range balances {
+		if b.Account == preflight.FeePayer && len(b.Mint) == 0 {
+			hasFeePayer = true
+			balances[i].Amount += totalFee
+		}
+	}
+	if !hasFeePayer {
+		balances = append(balances, solanago.BalanceCheck{
+			Account: preflight.FeePayer,
+			Amount:  totalFee,
+		})
+	}
+
+	for _, b := range balances {
+		var terr *types.Error
+		if len(b.Mint) == 0 {
+			terr = s.checkSolBalance(ctx, b, preflight.FeePayer, totalFee)
+		} else {
+			terr = s.checkTokenBalance(ctx, b)
+		}
+		if terr != nil {
+			return terr
+		}
+	}
+
+	for _, r := range preflight.Recipients {
+		if terr := s.checkRecipient(ctx, r); terr != nil {
+			return terr
+		}
+	}
+	return nil
+}
+
+func (s *ConstructionAPIService) checkSolBalance(
+	ctx context.Context,
+	b solanago.BalanceCheck,
+	feePayer string,
+	totalFee uint64,
+) *types.Error {
+	balance, err := s.client.GetBalance(ctx, b.Account)
+	if err != nil {
+		return nodeErr(ErrGeth, err)
+	}
+	if balance < b.Amount {
+		details := map[string]interface{}{
+			"account":  b.Account,
+			"currency": solanago.Symbol,
+			"balance":  strconv.FormatUint(balance, 10),
+			"required": strconv.FormatUint(b.Amount, 10),
+		}
+		if b.Account == feePayer {
+			details["fee"] = strconv.FormatUint(totalFee, 10)
+		}
+		return wrapErrDetails(ErrInsufficientBalance, details)
+	}
+	return nil
+}
+
+func (s *ConstructionAPIService) checkTokenBalance(
+	ctx context.Context,
+	b solanago.BalanceCheck,
+) *types.Error {
+	acc, err := s.client.GetAccountInfo(ctx, b.Account)
+	if err != nil {
+		return nodeErr(ErrGeth, err)
+	}
+
+	var amount string
+	if acc != nil && acc.Owner == common.TokenProgramID.ToBase58() {
+		info := acc.Data.Parsed.Info
+		if acc.Data.Parsed.Type != "account" || info.Mint != b.Mint {
+			return wrapErrDetails(ErrInvalidTokenAccount, map[string]interface{}{
+				"account":       b.Account,
+				"mint":          info.Mint,
+				"expected_mint": b.Mint,
+			})
+		}
+		amount = info.TokenAmount.Amount
+	} else {
+		// The sender is a wallet, use the token account construction
+		// resolves for it.
+		tokenAccs, err := s.client.GetTokenAccountsByMint(ctx, b.Account, b.Mint)
+		if err != nil {
+			return nodeErr(ErrGeth, err)
+		}
+		if len(tokenAccs) == 0 {
+			return wrapErrDetails(ErrTokenAccountNotFound, map[string]interface{}{
+				"owner": b.Account,
+				"mint":  b.Mint,
+			})
+		}
+		amount = tokenAccs[0].Account.Data.Parsed.Info.TokenAmount.Amount
+	}
+
+	balance, err := strconv.ParseUint(amount, 10, 64)
+	if err != nil {
+		return wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("%w: unable to parse token amount %s", err, amount))
+	}
+	if balance < b.Amount {
+		return wrapErrDetails(ErrInsufficientBalance, map[string]interface{}{
+			"account":  b.Account,
+			"currency": b.Mint,
+			"balance":  strconv.FormatUint(balance, 10),
+			"required": strconv.FormatUint(b.Amount, 10),
+		})
+	}
+	return nil
+}
+
+func (s *ConstructionAPIService) checkRecipient(
+	ctx context.Context,
+	r solanago.RecipientCheck,
+) *types.Error {
+	acc, err := s.client.GetAccountInfo(ctx, r.Account)
+	if err != nil {
+		return nodeErr(ErrGeth, err)
+	}
+	isTokenProgramAccount := acc != nil && acc.Owner == common.TokenProgramID.ToBase58()
+
+	switch r.Kind {
+	case solanago.RecipientTokenAccount:
+		if acc == nil {
+			return wrapErrDetails(ErrTokenAccountNotFound, map[string]interface{}{
+				"account": r.Account,
+				"mint":    r.Mint,
+			})
+		}
+		if !isTokenProgramAccount ||
+			acc.Data.Parsed.Type != "account" ||
+			acc.Data.Parsed.Info.Mint != r.Mint {
+			return wrapErrDetails(ErrInvalidTokenAccount, map[string]interface{}{
+				"account":       r.Account,
+				"owner":         acc.Owner,
+				"mint":          acc.Data.Parsed.Info.Mint,
+				"expected_mint": r.Mint,
+			})
+		}
+	case solanago.RecipientWallet:
+		if isTokenProgramAccount {
+			return wrapErrDetails(ErrInvalidRecipient, map[string]interface{}{
+				"account": r.Account,
+				"type":    acc.Data.Parsed.Type,
+				"reason":  "recipient must be a wallet, not a token program account",
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/configuration"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	ss "github.com/portto/solana-go-sdk/client"
+	"github.com/portto/solana-go-sdk/common"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+	"gotest.tools/assert"
+)
+
+func TestGetPreflight(t *testing.T) {
+	from := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	to := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	c := &types.Currency{Symbol: mint, Decimals: 2}
+
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-10", Currency: cSol},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                solanago.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "10", Currency: cSol},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 2},
+			Type:                solanago.SplToken__TransferWithSystem,
+			Account:             &types.AccountIdentifier{Address: from},
+			Amount:              &types.Amount{Value: "-5", Currency: c},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{Index: 3},
+			Type:                solanago.SplToken__TransferWithSystem,
+			Account:             &types.AccountIdentifier{Address: to},
+			Amount:              &types.Amount{Value: "5", Currency: c},
+		},
+	}
+	merged, terr := matchOperations(ops)
+	assert.Assert(t, terr == nil)
+	instructions, terr := operationsToInstructions(merged, nil)
+	assert.Assert(t, terr == nil)
+	preflight, terr := getPreflight(merged, instructions, "")
+	assert.Assert(t, terr == nil)
+
+	assert.Equal(t, from, preflight.FeePayer)
+	assert.DeepEqual(t, []solanago.BalanceCheck{
+		{Account: from, Amount: 10},
+		{Account: from, Mint: mint, Amount: 5},
+	}, preflight.Balances)
+	assert.DeepEqual(t, []solanago.RecipientCheck{
+		{Account: to, Mint: mint, Kind: solanago.RecipientWallet},
+	}, preflight.Recipients)
+
+	treasury := "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+	preflight, terr = getPreflight(merged, instructions, treasury)
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, treasury, preflight.FeePayer)
+	assert.DeepEqual(t, []string{treasury, from}, preflight.Signers)
+}
+
+func TestPreflightErrors(t *testing.T) {
+	ctx := context.Background()
+	wallet := solPTypes.NewAccount().PublicKey.ToBase58()
+	poor := solPTypes.NewAccount().PublicKey.ToBase58()
+	tokenAcc := solPTypes.NewAccount().PublicKey.ToBase58()
+	badAmountAcc := solPTypes.NewAccount().PublicKey.ToBase58()
+	otherMintAcc := solPTypes.NewAccount().PublicKey.ToBase58()
+	nonceAcc := solPTypes.NewAccount().PublicKey.ToBase58()
+	uninitialized := solPTypes.NewAccount().PublicKey.ToBase58()
+	authority := solPTypes.NewAccount().PublicKey.ToBase58()
+	mint := solPTypes.NewAccount().PublicKey.ToBase58()
+
+	tokenAccount := func(mint string, amount string) interface{} {
+		return map[string]interface{}{
+			"lamports": 2039280,
+			"owner":    common.TokenProgramID.ToBase58(),
+			"data": map[string]interface{}{
+				"program": "spl-token",
+				"parsed": map[string]interface{}{
+					"type": "account",
+					"info": map[string]interface{}{
+						"mint":        mint,
+						"tokenAmount": map[string]interface{}{"amount": amount, "decimals": 2},
+					},
+				},
+			},
+		}
+	}
+	nonceAccount := func(state string, lamportsPerSignature string) interface{} {
+		return map[string]interface{}{
+			"lamports": 1447680,
+			"owner":    common.SystemProgramID.ToBase58(),
+			"data": map[string]interface{}{
+				"program": "nonce",
+				"parsed": map[string]interface{}{
+					"type": state,
+					"info": map[string]interface{}{
+						"authority":     authority,
+						"blockhash":     "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+						"feeCalculator": map[string]interface{}{"lamportsPerSignature": lamportsPerSignature},
+					},
+				},
+			},
+		}
+	}
+	accounts := map[string]interface{}{
+		tokenAcc:      tokenAccount(mint, "3"),
+		badAmountAcc:  tokenAccount(mint, "x"),
+		otherMintAcc:  tokenAccount(solPTypes.NewAccount().PublicKey.ToBase58(), "100"),
+		nonceAcc:      nonceAccount("initialized", "5000"),
+		uninitialized: nonceAccount("uninitialized", "5000"),
+	}
+	client, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getAccountInfo": func(params []interface{}) interface{} {
+			return rpcContext(100, accounts[params[0].(string)])
+		},
+		"getBalance": func(params []interface{}) interface{} {
+			if params[0] == poor {
+				return rpcContext(100, 5000)
+			}
+			return rpcContext(100, 1000000)
+		},
+		"getTokenAccountsByOwner": func([]interface{}) interface{} {
+			return rpcContext(100, []interface{}{})
+		},
+	})
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Online}, client)
+	fee := ss.FeeCalculator{LamportsPerSignature: 5000}
+
+	for _, tt := range []struct {
+		name      string
+		preflight solanago.Preflight
+		err       *types.Error
+	}{
+		{
+			name: "sender cannot cover the fee",
+			preflight: solanago.Preflight{FeePayer: poor, Signers: []string{poor},
+				Balances: []solanago.BalanceCheck{{Account: poor, Amount: 1}}},
+			err: ErrInsufficientBalance,
+		},
+		{
+			name: "token account holds less than sent",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Balances: []solanago.BalanceCheck{{Account: tokenAcc, Mint: mint, Amount: 5}}},
+			err: ErrInsufficientBalance,
+		},
+		{
+			name: "token amount of the node cannot be parsed",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Balances: []solanago.BalanceCheck{{Account: badAmountAcc, Mint: mint, Amount: 5}}},
+			err: ErrUnableToParseIntermediateResult,
+		},
+		{
+			name: "sender token account of another mint",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Balances: []solanago.BalanceCheck{{Account: otherMintAcc, Mint: mint, Amount: 5}}},
+			err: ErrInvalidTokenAccount,
+		},
+		{
+			name: "sender wallet without token account",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Balances: []solanago.BalanceCheck{{Account: wallet, Mint: mint, Amount: 5}}},
+			err: ErrTokenAccountNotFound,
+		},
+		{
+			name: "recipient token account does not exist",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Recipients: []solanago.RecipientCheck{{Account: poor, Mint: mint, Kind: solanago.RecipientTokenAccount}}},
+			err: ErrTokenAccountNotFound,
+		},
+		{
+			name: "recipient token account of another mint",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Recipients: []solanago.RecipientCheck{{Account: otherMintAcc, Mint: mint, Kind: solanago.RecipientTokenAccount}}},
+			err: ErrInvalidTokenAccount,
+		},
+		{
+			name: "recipient wallet is a token account",
+			preflight: solanago.Preflight{FeePayer: wallet, Signers: []string{wallet},
+				Recipients: []solanago.RecipientCheck{{Account: tokenAcc, Mint: mint, Kind: solanago.RecipientWallet}}},
+			err: ErrInvalidRecipient,
+		},
+	} {
+		terr := s.checkPreflight(ctx, tt.preflight, fee, "")
+		assert.Assert(t, terr != nil, tt.name)
+		assert.Equal(t, tt.err.Code, terr.Code, tt.name)
+	}
+	terr := s.checkPreflight(ctx, solanago.Preflight{FeePayer: poor, Signers: []string{poor}}, fee, "")
+	assert.Assert(t, terr == nil)
+
+	for _, tt := range []struct {
+		withNonce solanago.WithNonce
+		err       *types.Error
+	}{
+		{solanago.WithNonce{Account: wallet}, ErrNonceAccountNotFound},
+		{solanago.WithNonce{Account: uninitialized}, ErrNonceNotInitialized},
+		{solanago.WithNonce{Account: nonceAcc, Authority: wallet}, ErrNonceAuthorityMismatch},
+	} {
+		_, terr := s.getNonce(ctx, tt.withNonce)
+		assert.Assert(t, terr != nil)
+		assert.Equal(t, tt.err.Code, terr.Code)
+	}
+	nonce, terr := s.getNonce(ctx, solanago.WithNonce{Account: nonceAcc, Authority: authority})
+	assert.Assert(t, terr == nil)
+	assert.Equal(t, authority, nonce.Authority)
+
+	// A nonce fee the node reports in another format is not read as 0.
+	accounts[nonceAcc] = nonceAccount("initialized", "1.5")
+	_, terr = s.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{
+		Options: map[string]interface{}{solanago.WithNonceKey: map[string]interface{}{"account": nonceAcc}},
+	})
+	assert.Equal(t, ErrUnableToParseIntermediateResult.Code, terr.Code)
+
+	// A token transfer to a wallet must name the wallet.
+	c := &types.Currency{Symbol: mint, Decimals: 2}
+	_, terr = s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                solanago.SplToken__TransferWithSystem,
+			Account:             &types.AccountIdentifier{Address: wallet},
+			Amount:              &types.Amount{Value: "-5", Currency: c},
+		}},
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+}
@@ -56,6 +56,11 @@ func NewBlockchainRouter(
 	)
 
 	callAPIService := NewCallAPIService(config, client)
+	callAPIService.submitter = constructionAPIService.submitter
+	callAPIService.blockCache = blockAPIService.cache
+	if blockAPIService.cache != nil {
+		registerBlockCacheMetrics(blockAPIService.cache)
+	}
 	callAPIController := server.NewCallAPIController(
 		callAPIService,
 		asserter,
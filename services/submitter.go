@@ -0,0 +1,287 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/imerkle/rosetta-solana-go/logger"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+const (
+	// blockhashLifetime is how long a transaction is rebroadcast when
+	// the node cannot tell if its blockhash is still valid.
+	blockhashLifetime = 2 * time.Minute
+
+	// submitRetention is how long the outcome of a transaction is
+	// kept after it stopped being rebroadcast.
+	submitRetention = time.Hour
+)
+
+// submitter tracks the transactions sent by /construction/submit. It
+// rebroadcasts each one until it reaches commitment, fails or its
+// blockhash or durable nonce expires. Transactions are checked every
+// interval by a background loop that runs while any is pending.
+type submitter struct {
+	client     *solanago.Client
+	commitment string
+	interval   time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	txs     map[string]*submittedTx
+	running bool
+}
+
+// submittedTx is a transaction sent by /construction/submit. sent is
+// when it was last broadcast and done when it stopped being pending.
+// commitment is the one it was sent at; its blockhash or nonce is
+// checked at that commitment.
+type submittedTx struct {
+	signature    string
+	rawTx        []byte
+	blockhash    string
+	nonceAccount string
+	commitment   string
+	submitted    time.Time
+	sent         time.Time
+	done         time.Time
+	status       StatusResult
+}
+
+func newSubmitter(client *solanago.Client, commitment string, interval time.Duration) *submitter {
+	return &submitter{
+		client:     client,
+		commitment: commitment,
+		interval:   interval,
+		now:        time.Now,
+		txs:        make(map[string]*submittedTx),
+	}
+}
+
+// track starts tracking tx, sent with signature at commitment.
+func (s *submitter) track(signature string, tx solPTypes.Transaction, rawTx []byte, commitment string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.txs {
+		if !v.done.IsZero() && s.now().Sub(v.done) > submitRetention {
+			delete(s.txs, k)
+		}
+	}
+	nonceAccount, _ := nonceAccount(tx)
+	s.txs[signature] = &submittedTx{
+		signature:    signature,
+		rawTx:        rawTx,
+		blockhash:    tx.Message.RecentBlockHash,
+		nonceAccount: nonceAccount,
+		commitment:   commitment,
+		submitted:    s.now(),
+		sent:         s.now(),
+		status: StatusResult{
+			Hash:   signature,
+			Status: solanago.TransactionPending,
+		},
+	}
+	if s.interval > 0 && !s.running {
+		s.running = true
+		go s.run()
+	}
+}
+
+// status returns the status of the transaction sent with signature.
+func (s *submitter) status(signature string) (StatusResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.txs[signature]
+	if !ok {
+		return StatusResult{}, false
+	}
+	return v.status, true
+}
+
+// resubmit returns the status of the transaction sent with signature
+// if it is tracked. A pending transaction is broadcast again if its
+// last broadcast is older than the rebroadcast interval; others are
+// not sent again.
+func (s *submitter) resubmit(ctx context.Context, signature string) (StatusResult, bool) {
+	s.mu.Lock()
+	v, ok := s.txs[signature]
+	if !ok {
+		s.mu.Unlock()
+		return StatusResult{}, false
+	}
+	due := v.done.IsZero() && s.now().Sub(v.sent) >= s.interval
+	status := v.status
+	s.mu.Unlock()
+
+	if due {
+		s.rebroadcast(ctx, v)
+		status, _ = s.status(signature)
+	}
+	return status, true
+}
+
+// rebroadcast sends v again.
+func (s *submitter) rebroadcast(ctx context.Context, v *submittedTx) {
+	_, err := s.client.SendTransaction(ctx, v.rawTx, true, s.commitment)
+	if err != nil && !solanago.IsAlreadyProcessed(err) {
+		logger.FromContext(ctx).Warn("unable to rebroadcast transaction", "signature", v.signature, "error", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v.sent = s.now()
+	v.status.Rebroadcasts++
+}
+
+// run polls the pending transactions every interval until none is
+// left.
+func (s *submitter) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+		pending := s.poll(ctx)
+		cancel()
+		if pending == 0 {
+			s.mu.Lock()
+			// A transaction tracked while polling keeps the loop
+			// running.
+			if len(s.pending()) == 0 {
+				s.running = false
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *submitter) pending() []*submittedTx {
+	var pending []*submittedTx
+	for _, v := range s.txs {
+		if v.done.IsZero() {
+			pending = append(pending, v)
+		}
+	}
+	return pending
+}
+
+// poll checks the pending transactions once, rebroadcasts those the
+// node does not know and returns how many are still pending.
+func (s *submitter) poll(ctx context.Context) int {
+	s.mu.Lock()
+	pending := s.pending()
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return 0
+	}
+
+	signatures := make([]string, len(pending))
+	for i, v := range pending {
+		signatures[i] = v.signature
+	}
+	statuses, err := s.client.GetSignatureStatuses(ctx, signatures)
+	if err != nil {
+		logger.FromContext(ctx).Warn("unable to get status of submitted transactions", "error", err)
+		return len(pending)
+	}
+
+	left := 0
+	for i, v := range pending {
+		var status *solanago.SignatureStatus
+		if i < len(statuses) {
+			status = statuses[i]
+		}
+		if !s.check(ctx, v, status) {
+			left++
+		}
+	}
+	return left
+}
+
+// check updates the status of the pending transaction v, known to the
+// node as status, and returns true once v is no longer pending.
+func (s *submitter) check(ctx context.Context, v *submittedTx, status *solanago.SignatureStatus) bool {
+	if status != nil {
+		reached := solanago.CommitmentRank(status.ConfirmationStatus) >= solanago.CommitmentRank(s.commitment)
+		result := StatusResult{
+			Hash:       v.signature,
+			Status:     solanago.TransactionPending,
+			Commitment: status.ConfirmationStatus,
+			Slot:       status.Slot,
+			Err:        status.Err,
+		}
+		if status.Err != nil {
+			result.Status = solanago.TransactionFailed
+		} else if reached {
+			result.Status = solanago.TransactionConfirmed
+		}
+		if result.Status != solanago.TransactionPending {
+			// Failed transactions pay their fee too.
+			readCtx := solanago.WithCommitment(ctx, status.ConfirmationStatus)
+			outcome, err := s.client.GetTransactionOutcome(readCtx, v.signature)
+			if err != nil {
+				logger.FromContext(ctx).Warn("unable to get fee of transaction", "signature", v.signature, "error", err)
+			} else if outcome != nil {
+				result.Fee = outcome.Fee
+			}
+		}
+		s.update(v, result)
+		return result.Status != solanago.TransactionPending
+	}
+
+	if s.expired(ctx, v) {
+		s.update(v, StatusResult{Hash: v.signature, Status: solanago.TransactionExpired})
+		return true
+	}
+	// The transaction was dropped or not yet seen by the node.
+	s.rebroadcast(ctx, v)
+	return false
+}
+
+// update sets the status of v, keeping its rebroadcast count.
+func (s *submitter) update(v *submittedTx, result StatusResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result.Rebroadcasts = v.status.Rebroadcasts
+	v.status = result
+	if result.Status != solanago.TransactionPending {
+		v.done = s.now()
+	}
+}
+
+// expired returns true if v can no longer be processed: its durable
+// nonce was advanced or its blockhash is no longer valid.
+func (s *submitter) expired(ctx context.Context, v *submittedTx) bool {
+	ctx = solanago.WithCommitment(ctx, v.commitment)
+	if len(v.nonceAccount) > 0 {
+		acc, err := s.client.GetAccountInfo(ctx, v.nonceAccount)
+		if err != nil {
+			return false
+		}
+		return acc == nil || acc.Data.Parsed.Info.Blockhash != v.blockhash
+	}
+	valid, err := s.client.IsBlockhashValid(ctx, v.blockhash)
+	if err != nil {
+		return s.now().Sub(v.submitted) > blockhashLifetime
+	}
+	return !valid
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/configuration"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+	"gotest.tools/assert"
+)
+
+func TestConstructionSubmitRebroadcast(t *testing.T) {
+	ctx := context.Background()
+	cSol := &types.Currency{Symbol: solanago.Symbol, Decimals: solanago.Decimals}
+	valid := true
+	statuses := map[string]interface{}{}
+	sends := map[string]int{}
+	processed := map[string]bool{}
+	client, rpc := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"sendTransaction": func(params []interface{}) interface{} {
+			tx, _, err := solanago.DecodeTx(params[0].(string))
+			assert.NilError(t, err)
+			signature := tx.Signatures[0].ToBase58()
+			sends[signature]++
+			if processed[signature] {
+				return &solanago.RPCError{Code: -32002, Message: "Transaction simulation failed: This transaction has already been processed"}
+			}
+			return signature
+		},
+		"getSignatureStatuses": func(params []interface{}) interface{} {
+			var res []interface{}
+			for _, v := range params[0].([]interface{}) {
+				res = append(res, statuses[v.(string)])
+			}
+			return rpcContext(100, res)
+		},
+		"isBlockhashValid": func([]interface{}) interface{} {
+			return rpcContext(100, valid)
+		},
+		"getConfirmedTransaction": func(params []interface{}) interface{} {
+			status := statuses[params[0].(string)].(map[string]interface{})
+			return map[string]interface{}{
+				"slot": status["slot"],
+				"meta": map[string]interface{}{"err": status["err"], "fee": 5000},
+			}
+		},
+	})
+	cfg := &configuration.Configuration{Mode: configuration.Online, RebroadcastInterval: time.Minute}
+	s := NewConstructionAPIService(cfg, client)
+	// The test polls the submitter itself.
+	s.submitter.running = true
+	now := time.Now()
+	s.submitter.now = func() time.Time { return now }
+	c := NewCallAPIService(cfg, client)
+	c.submitter = s.submitter
+
+	sender := solPTypes.NewAccount()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	signed := func(lamports int) (string, string) {
+		payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Operations: []*types.Operation{{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                solanago.System__Transfer,
+				Account:             &types.AccountIdentifier{Address: sender.PublicKey.ToBase58()},
+				Amount:              &types.Amount{Value: fmt.Sprint(-lamports), Currency: cSol},
+			}, {
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				Type:                solanago.System__Transfer,
+				Account:             &types.AccountIdentifier{Address: to},
+				Amount:              &types.Amount{Value: fmt.Sprint(lamports), Currency: cSol},
+			}},
+			Metadata: map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+		})
+		assert.Assert(t, terr == nil)
+		combRes, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+			UnsignedTransaction: payRes.UnsignedTransaction,
+			Signatures: []*types.Signature{{
+				SigningPayload: payRes.Payloads[0],
+				PublicKey:      &types.PublicKey{Bytes: sender.PublicKey.Bytes(), CurveType: types.Edwards25519},
+				SignatureType:  types.Ed25519,
+				Bytes:          ed25519.Sign(sender.PrivateKey, payRes.Payloads[0].Bytes),
+			}},
+		})
+		assert.Assert(t, terr == nil)
+		tx, err := solanago.GetTxFromStr(combRes.SignedTransaction)
+		assert.NilError(t, err)
+		return combRes.SignedTransaction, tx.Signatures[0].ToBase58()
+	}
+	submit := func(signedTx string) (string, string) {
+		res, terr := s.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{SignedTransaction: signedTx})
+		assert.Assert(t, terr == nil)
+		return res.TransactionIdentifier.Hash, res.Metadata[solanago.StatusKey].(string)
+	}
+	status := func(hash string) StatusResult {
+		res, terr := c.Call(ctx, &types.CallRequest{
+			Method:     solanago.CallStatus,
+			Parameters: map[string]interface{}{"hash": hash},
+		})
+		assert.Assert(t, terr == nil)
+		var result StatusResult
+		assert.NilError(t, unmarshalJSONMap(res.Result, &result))
+		return result
+	}
+
+	// A transaction the node does not know is rebroadcast, and one it
+	// processed is not until it reaches the submit commitment.
+	tx1, sig1 := signed(1000)
+	hash, st := submit(tx1)
+	assert.Equal(t, sig1, hash)
+	assert.Equal(t, solanago.TransactionPending, st)
+	assert.Equal(t, solanago.TransactionPending, status(sig1).Status)
+	assert.Equal(t, 1, s.submitter.poll(ctx))
+	assert.Equal(t, 2, sends[sig1])
+	assert.Equal(t, 1, status(sig1).Rebroadcasts)
+
+	// Resubmitting a pending transaction only sends it again once a
+	// rebroadcast is due.
+	hash, st = submit(tx1)
+	assert.Equal(t, sig1, hash)
+	assert.Equal(t, solanago.TransactionPending, st)
+	assert.Equal(t, 2, sends[sig1])
+	now = now.Add(time.Minute)
+	submit(tx1)
+	assert.Equal(t, 3, sends[sig1])
+	assert.Equal(t, 2, status(sig1).Rebroadcasts)
+
+	statuses[sig1] = map[string]interface{}{"slot": 120, "err": nil, "confirmationStatus": "processed"}
+	assert.Equal(t, 1, s.submitter.poll(ctx))
+	assert.Equal(t, 3, sends[sig1])
+	assert.Equal(t, solanago.ProcessedCommitment, status(sig1).Commitment)
+	statuses[sig1] = map[string]interface{}{"slot": 120, "err": nil, "confirmationStatus": "confirmed"}
+	assert.Equal(t, 0, s.submitter.poll(ctx))
+	assert.DeepEqual(t, StatusResult{
+		Hash:         sig1,
+		Status:       solanago.TransactionConfirmed,
+		Commitment:   solanago.ConfirmedCommitment,
+		Slot:         120,
+		Fee:          5000,
+		Rebroadcasts: 2,
+	}, status(sig1))
+
+	// A confirmed transaction is never sent again.
+	now = now.Add(time.Minute)
+	hash, st = submit(tx1)
+	assert.Equal(t, sig1, hash)
+	assert.Equal(t, solanago.TransactionConfirmed, st)
+	assert.Equal(t, 3, sends[sig1])
+
+	// A transaction the node already processed is submitted.
+	tx2, sig2 := signed(2000)
+	processed[sig2] = true
+	hash, st = submit(tx2)
+	assert.Equal(t, sig2, hash)
+	assert.Equal(t, solanago.TransactionPending, st)
+
+	// Failed transactions report their error and fee, and transactions
+	// whose blockhash expired stop being rebroadcast.
+	statuses[sig2] = map[string]interface{}{
+		"slot":               121,
+		"err":                map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}},
+		"confirmationStatus": "processed",
+	}
+	tx3, sig3 := signed(3000)
+	submit(tx3)
+	valid = false
+	assert.Equal(t, 0, s.submitter.poll(ctx))
+	failed := status(sig2)
+	assert.Equal(t, solanago.TransactionFailed, failed.Status)
+	assert.Equal(t, uint64(121), failed.Slot)
+	assert.Equal(t, uint64(5000), failed.Fee)
+	assert.Assert(t, failed.Err != nil)
+	assert.Equal(t, solanago.TransactionExpired, status(sig3).Status)
+	assert.Equal(t, 1, sends[sig3])
+	// The blockhash is checked at the commitment it was sent at, not
+	// at the read commitment.
+	for _, req := range rpc.requests {
+		if req.Method == "isBlockhashValid" {
+			assert.DeepEqual(t, map[string]interface{}{"commitment": solanago.ConfirmedCommitment}, req.Params[1])
+		}
+	}
+
+	// Transactions not sent by submit are looked up on the node.
+	statuses["sig4"] = map[string]interface{}{"slot": 122, "err": nil, "confirmationStatus": "finalized"}
+	assert.Equal(t, solanago.TransactionConfirmed, status("sig4").Status)
+	assert.Equal(t, uint64(5000), status("sig4").Fee)
+	assert.Equal(t, solanago.TransactionUnknown, status("sig5").Status)
+}
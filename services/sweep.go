@@ -0,0 +1,153 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	ss "github.com/portto/solana-go-sdk/client"
+)
+
+// getSweeps returns the sweeps of the merged operations keyed by
+// operation index. A SplToken__Sweep takes its mint from the "mint"
+// metadata or the currency of its amount.
+func getSweeps(merged []*types.Operation) (map[int64]solanago.Sweep, *types.Error) {
+	sweeps := make(map[int64]solanago.Sweep)
+	for _, op := range merged {
+		if op.Type != solanago.System__Sweep && op.Type != solanago.SplToken__Sweep {
+			continue
+		}
+		index := op.OperationIdentifier.Index
+		sweep := solanago.Sweep{Source: op.Account.Address}
+		sweep.Destination, _ = op.Metadata["destination"].(string)
+		if !solanago.IsValidAddress(sweep.Destination) {
+			return nil, wrapErrDetails(ErrInvalidAddress, map[string]interface{}{
+				"destination": sweep.Destination,
+				"indices":     []int64{index},
+			})
+		}
+		if op.Type == solanago.SplToken__Sweep {
+			sweep.Mint, _ = op.Metadata["mint"].(string)
+			if len(sweep.Mint) == 0 && op.Amount != nil {
+				sweep.Mint = op.Amount.Currency.Symbol
+			}
+			if !solanago.IsValidAddress(sweep.Mint) {
+				return nil, unclearIntentErr("sweep has no mint", index)
+			}
+		}
+		sweeps[index] = sweep
+	}
+	return sweeps, nil
+}
+
+// resolveSweeps resolves the amount of every sweep in options. A SOL
+// sweep sends the balance less the other amounts the account sends
+// and, when it pays it, the fee. A token sweep sends the balance of
+// the token account construction resolved in splTokenAccMap.
+func (s *ConstructionAPIService) resolveSweeps(
+	ctx context.Context,
+	options map[string]interface{},
+	splTokenAccMap map[string]solanago.SplAccounts,
+	preflight solanago.Preflight,
+	fee ss.FeeCalculator,
+	nonceAuthority string,
+) (map[string]solanago.Sweep, *types.Error) {
+	w, ok := options[solanago.SweepKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	var sweeps map[string]solanago.Sweep
+	if err := unmarshalJSONMap(w, &sweeps); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	var minSlot uint64
+	if slot, ok := options[solanago.SlotKey].(float64); ok && slot > 0 {
+		minSlot = uint64(slot)
+	}
+
+	for k, sweep := range sweeps {
+		if len(sweep.Mint) > 0 {
+			account := splTokenAccMap[k].Source
+			if len(account) == 0 {
+				return nil, wrapErrDetails(ErrTokenAccountNotFound, map[string]interface{}{
+					"owner": sweep.Source,
+					"mint":  sweep.Mint,
+				})
+			}
+			amount, decimals, slot, err := s.client.GetTokenBalanceAt(ctx, account, minSlot)
+			if err != nil {
+				return nil, nodeErr(ErrGeth, err)
+			}
+			sweep.Amount, sweep.Decimals, sweep.Slot = amount, decimals, slot
+			sweeps[k] = sweep
+			continue
+		}
+
+		balance, slot, err := s.client.GetBalanceAt(ctx, sweep.Source, minSlot)
+		if err != nil {
+			return nil, nodeErr(ErrGeth, err)
+		}
+		var spent uint64
+		for _, b := range preflight.Balances {
+			if b.Account == sweep.Source && len(b.Mint) == 0 {
+				spent += b.Amount
+			}
+		}
+		if sweep.Source == preflight.FeePayer {
+			spent += transactionFee(preflight, fee, nonceAuthority)
+		}
+		if balance <= spent {
+			return nil, wrapErrDetails(ErrInsufficientBalance, map[string]interface{}{
+				"account":  sweep.Source,
+				"currency": solanago.Symbol,
+				"balance":  strconv.FormatUint(balance, 10),
+				"required": strconv.FormatUint(spent+1, 10),
+				"slot":     slot,
+			})
+		}
+		sweep.Amount, sweep.Slot = balance-spent, slot
+		sweeps[k] = sweep
+	}
+	return sweeps, nil
+}
+
+// applySweeps sets the amounts /construction/metadata resolved on the
+// sweep operations of merged.
+func applySweeps(merged []*types.Operation, sweeps map[string]solanago.Sweep) *types.Error {
+	for _, op := range merged {
+		if op.Type != solanago.System__Sweep && op.Type != solanago.SplToken__Sweep {
+			continue
+		}
+		sweep, ok := sweeps[fmt.Sprint(op.OperationIdentifier.Index)]
+		if !ok {
+			return unclearIntentErr("sweep amount not resolved", op.OperationIdentifier.Index)
+		}
+		if op.Metadata == nil {
+			op.Metadata = make(map[string]interface{})
+		}
+		if op.Type == solanago.System__Sweep {
+			op.Metadata["lamports"] = sweep.Amount
+		} else {
+			op.Metadata["mint"] = sweep.Mint
+			op.Metadata["amount"] = sweep.Amount
+			op.Metadata["decimals"] = sweep.Decimals
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/configuration"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+	"gotest.tools/assert"
+)
+
+func TestConstructionSweep(t *testing.T) {
+	ctx := context.Background()
+	owner := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+	mint := solPTypes.NewAccount().PublicKey.ToBase58()
+	tokenAcc := solPTypes.NewAccount().PublicKey.ToBase58()
+	blockhash := "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"
+
+	client, rpc := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getRecentBlockhash": func([]interface{}) interface{} {
+			return rpcContext(100, map[string]interface{}{
+				"blockhash":     blockhash,
+				"feeCalculator": map[string]interface{}{"lamportsPerSignature": 5000},
+			})
+		},
+		"getBalance": func([]interface{}) interface{} {
+			return rpcContext(101, 1000000)
+		},
+		"getTokenAccountsByOwner": func(params []interface{}) interface{} {
+			if params[0] != owner {
+				return rpcContext(101, []interface{}{})
+			}
+			return rpcContext(101, []interface{}{map[string]interface{}{"pubkey": tokenAcc}})
+		},
+		"getTokenAccountBalance": func([]interface{}) interface{} {
+			return rpcContext(102, map[string]interface{}{"amount": "250", "decimals": 2})
+		},
+	})
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Online}, client)
+
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                solanago.SplToken__Sweep,
+		Account:             &types.AccountIdentifier{Address: owner},
+		Metadata:            map[string]interface{}{"destination": to, "mint": mint},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                solanago.System__Sweep,
+		Account:             &types.AccountIdentifier{Address: owner},
+		Metadata:            map[string]interface{}{"destination": to},
+	}}
+	preRes, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{"slot": 90},
+	})
+	assert.Assert(t, terr == nil)
+	options := map[string]interface{}{}
+	b, _ := json.Marshal(preRes.Options)
+	json.Unmarshal(b, &options)
+
+	metaRes, terr := s.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{Options: options})
+	assert.Assert(t, terr == nil)
+	var meta ConstructionMetadata
+	assert.NilError(t, unmarshalJSONMap(metaRes.Metadata, &meta))
+	assert.DeepEqual(t, solanago.Sweep{Source: owner, Destination: to, Mint: mint, Amount: 250, Decimals: 2, Slot: 102}, meta.Sweeps["0"])
+	// The owner pays the fee of its one signature.
+	assert.DeepEqual(t, solanago.Sweep{Source: owner, Destination: to, Amount: 995000, Slot: 101}, meta.Sweeps["1"])
+	for _, req := range rpc.requests {
+		if req.Method == "getBalance" && len(req.Params) > 1 {
+			assert.DeepEqual(t, map[string]interface{}{"minContextSlot": float64(90)}, req.Params[1])
+		}
+	}
+
+	payRes, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata:   metaRes.Metadata,
+	})
+	assert.Assert(t, terr == nil)
+	parseRes, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payRes.UnsignedTransaction,
+	})
+	assert.Assert(t, terr == nil)
+	var opTypes []string
+	for _, op := range parseRes.Operations {
+		opTypes = append(opTypes, op.Type)
+	}
+	assert.DeepEqual(t, []string{
+		solanago.SplToken__Sweep,
+		solanago.System__Transfer,
+		solanago.System__Transfer,
+	}, opTypes)
+	// The token account of the owner is not its associated account.
+	assert.DeepEqual(t, map[string]interface{}{
+		"destination":  to,
+		"mint":         mint,
+		"source_token": tokenAcc,
+	}, parseRes.Operations[0].Metadata)
+	assert.Equal(t, "995000", parseRes.Operations[2].Amount.Value)
+
+	_, terr = s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{"blockhash": blockhash},
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, terr.Code)
+}
+
+func TestConstructionSweepFeePayer(t *testing.T) {
+	ctx := context.Background()
+	owner := solPTypes.NewAccount().PublicKey.ToBase58()
+	to := solPTypes.NewAccount().PublicKey.ToBase58()
+
+	client, _ := newFakeClient(t, map[string]func([]interface{}) interface{}{
+		"getRecentBlockhash": func([]interface{}) interface{} {
+			return rpcContext(100, map[string]interface{}{
+				"blockhash":     "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				"feeCalculator": map[string]interface{}{"lamportsPerSignature": 5000},
+			})
+		},
+		"getBalance": func([]interface{}) interface{} {
+			return rpcContext(101, 1000000)
+		},
+	})
+	s := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Online}, client)
+
+	// The owner pays the fee once for both its transfer and its sweep.
+	preRes, terr := s.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: []*types.Operation{
+			transfer(0, owner, "-1000"),
+			transfer(1, to, "1000", 0),
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 2},
+				Type:                solanago.System__Sweep,
+				Account:             &types.AccountIdentifier{Address: owner},
+				Metadata:            map[string]interface{}{"destination": to},
+			},
+		},
+	})
+	assert.Assert(t, terr == nil)
+	options := map[string]interface{}{}
+	b, _ := json.Marshal(preRes.Options)
+	json.Unmarshal(b, &options)
+
+	metaRes, terr := s.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{Options: options})
+	assert.Assert(t, terr == nil)
+	var meta ConstructionMetadata
+	assert.NilError(t, unmarshalJSONMap(metaRes.Metadata, &meta))
+	assert.DeepEqual(t, solanago.Sweep{Source: owner, Destination: to, Amount: 994000, Slot: 101}, meta.Sweeps["2"])
+}
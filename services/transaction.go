@@ -0,0 +1,189 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	ss "github.com/portto/solana-go-sdk/client"
+	"github.com/portto/solana-go-sdk/common"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+// placeholderBlockhash stands in for the blockhash when transactions
+// are built before /construction/metadata fetched one. It has the
+// size of a real blockhash.
+const placeholderBlockhash = "11111111111111111111111111111111"
+
+// buildTransaction builds the unsigned transaction of instructions
+// and returns it with its signers in signature order. feePayer pays
+// the fee and defaults to the first signer of instructions.
+func buildTransaction(
+	instructions []solPTypes.Instruction,
+	blockHash string,
+	withNonce *solanago.WithNonce,
+	feePayerAddress string,
+) (solPTypes.Transaction, []string, *types.Error) {
+	if len(feePayerAddress) == 0 {
+		signers := solPTypes.GetUniqueSigners(instructions)
+		if len(signers) == 0 {
+			return solPTypes.Transaction{}, nil, wrapErr(ErrUnclearIntent, fmt.Errorf("no signers"))
+		}
+		feePayerAddress = signers[0]
+	}
+	feePayer := common.PublicKeyFromString(feePayerAddress)
+
+	var message solPTypes.Message
+	if withNonce != nil {
+		message = ss.NewMessageWithNonce(feePayer, instructions, common.PublicKeyFromString(withNonce.Account), common.PublicKeyFromString(withNonce.Authority))
+	} else {
+		message = solPTypes.NewMessage(feePayer, instructions, blockHash)
+	}
+
+	//unsigned signature
+	var sig []solPTypes.Signature
+	x := make([]byte, 64)
+	for i := 0; i < int(message.Header.NumRequireSignatures); i++ {
+		sig = append(sig, x)
+	}
+	tx := solPTypes.Transaction{
+		Signatures: sig,
+		Message:    message,
+	}
+	tx.Message.RecentBlockHash = blockHash
+	return tx, messageSigners(tx.Message), nil
+}
+
+// messageSigners returns the accounts that must sign message, fee
+// payer first.
+func messageSigners(message solPTypes.Message) []string {
+	var signers []string
+	for _, v := range message.Accounts[:message.Header.NumRequireSignatures] {
+		signers = append(signers, v.ToBase58())
+	}
+	return signers
+}
+
+// serializeTransaction serializes tx and checks it against the packet
+// size and account limits.
+func serializeTransaction(tx solPTypes.Transaction) ([]byte, *types.Error) {
+	b, err := tx.Serialize()
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	if len(b) > solanago.MaxTransactionSize {
+		return nil, wrapErrDetails(ErrTransactionTooLarge, map[string]interface{}{
+			"size":         len(b),
+			"max_size":     solanago.MaxTransactionSize,
+			"instructions": len(tx.Message.Instructions),
+		})
+	}
+	if len(tx.Message.Accounts) > solanago.MaxTransactionAccounts {
+		return nil, wrapErrDetails(ErrTooManyAccounts, map[string]interface{}{
+			"accounts":     len(tx.Message.Accounts),
+			"max_accounts": solanago.MaxTransactionAccounts,
+			"instructions": len(tx.Message.Instructions),
+		})
+	}
+	return b, nil
+}
+
+// checkOperations checks that the merged operations fit in one
+// transaction, or in split mode that each of them fits in one.
+func checkOperations(
+	merged []*types.Operation,
+	withNonce *solanago.WithNonce,
+	feePayer string,
+	split bool,
+) *types.Error {
+	if split {
+		_, terr := splitOperations(merged, nil, placeholderBlockhash, withNonce, feePayer)
+		return terr
+	}
+	instructions, terr := operationsToInstructions(merged, nil)
+	if terr != nil {
+		return terr
+	}
+	tx, _, terr := buildTransaction(instructions, placeholderBlockhash, withNonce, feePayer)
+	if terr != nil {
+		return terr
+	}
+	_, terr = serializeTransaction(tx)
+	return terr
+}
+
+// splitOperations splits the merged operations, in order, into batches
+// that each fit in one transaction. The instructions of one operation
+// are never split across transactions.
+func splitOperations(
+	merged []*types.Operation,
+	splTokenAccMap map[string]solanago.SplAccounts,
+	blockHash string,
+	withNonce *solanago.WithNonce,
+	feePayer string,
+) ([][]*types.Operation, *types.Error) {
+	if withNonce != nil {
+		// Every transaction would advance the same nonce, so only the
+		// first one could land.
+		return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("split is not supported with a durable nonce"))
+	}
+
+	fits := func(batch []*types.Operation) *types.Error {
+		instructions, terr := operationsToInstructions(batch, splTokenAccMap)
+		if terr != nil {
+			return terr
+		}
+		tx, _, terr := buildTransaction(instructions, blockHash, withNonce, feePayer)
+		if terr != nil {
+			return terr
+		}
+		_, terr = serializeTransaction(tx)
+		return terr
+	}
+
+	var batches [][]*types.Operation
+	var current []*types.Operation
+	for _, op := range merged {
+		candidate := append(append([]*types.Operation{}, current...), op)
+		terr := fits(candidate)
+		if terr == nil {
+			current = candidate
+			continue
+		}
+		if len(current) > 0 && isLimitErr(terr) {
+			if terr = fits([]*types.Operation{op}); terr == nil {
+				batches = append(batches, current)
+				current = []*types.Operation{op}
+				continue
+			}
+		}
+		if isLimitErr(terr) {
+			terr.Details["indices"] = []int64{op.OperationIdentifier.Index}
+		}
+		return nil, terr
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches, nil
+}
+
+// isLimitErr returns true if terr is returned by serializeTransaction
+// for a transaction over the limits.
+func isLimitErr(terr *types.Error) bool {
+	return terr.Code == ErrTransactionTooLarge.Code || terr.Code == ErrTooManyAccounts.Code
+}
@@ -58,9 +58,52 @@ type ConstructionMetadata struct {
 	BlockHash         string                          `json:"blockhash"`
 	FeeCalculator     ss.FeeCalculator                `json:"fee_calculator"`
 	SplTokenAccMapKey map[string]solanago.SplAccounts `json:"spl_token_acc_map"`
+	WithNonce         *solanago.WithNonce             `json:"with_nonce,omitempty"`
+	Split             bool                            `json:"split,omitempty"`
+	FeePayer          string                          `json:"fee_payer,omitempty"`
+	Sweeps            map[string]solanago.Sweep       `json:"sweeps,omitempty"`
+	Encoding          string                          `json:"encoding,omitempty"`
+	Commitment        string                          `json:"commitment,omitempty"`
 }
 
 type MetadataWithFee struct {
 	Metadata     ConstructionMetadata `json:"metadata"`
 	SuggestedFee []*types.Amount      `json:"suggestedFee"`
 }
+
+// BlockCacheStats is the /call result of solanago.CallBlockCacheStats.
+// Hits and DiskHits count the blocks and transactions served from
+// memory and disk, Invalidations the blocks dropped before finalization
+// and Blocks and DiskBlocks the blocks cached.
+type BlockCacheStats struct {
+	Hits          uint64 `json:"hits"`
+	DiskHits      uint64 `json:"disk_hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Blocks        int    `json:"blocks"`
+	DiskBlocks    int    `json:"disk_blocks"`
+}
+
+// SimulateResult is the /call result of solanago.CallSimulate.
+type SimulateResult struct {
+	Status        string             `json:"status"`
+	Err           interface{}        `json:"err"`
+	Logs          []string           `json:"logs"`
+	UnitsConsumed uint64             `json:"units_consumed"`
+	Slot          uint64             `json:"slot"`
+	Operations    []*types.Operation `json:"operations"`
+}
+
+// StatusResult is the /call result of solanago.CallStatus. Commitment
+// is the commitment the transaction reached, and Slot, Err and Fee its
+// outcome once it is no longer pending.
+type StatusResult struct {
+	Hash         string      `json:"hash"`
+	Status       string      `json:"status"`
+	Commitment   string      `json:"commitment,omitempty"`
+	Slot         uint64      `json:"slot,omitempty"`
+	Err          interface{} `json:"err"`
+	Fee          uint64      `json:"fee,omitempty"`
+	Rebroadcasts int         `json:"rebroadcasts"`
+}
@@ -15,9 +15,27 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
 )
 
+// withCommitment returns ctx reading at the commitment set in m, if
+// any, instead of the configured commitment.
+func withCommitment(ctx context.Context, m map[string]interface{}) (context.Context, *types.Error) {
+	commitment, ok := solanago.GetCommitment(m)
+	if !ok {
+		return ctx, nil
+	}
+	if !solanago.IsValidCommitment(commitment) {
+		return nil, wrapErr(ErrUnsupportedCommitment, fmt.Errorf("unsupported commitment %s", commitment))
+	}
+	return solanago.WithCommitment(ctx, commitment), nil
+}
+
 // *JSONMap functions are needed because `types.MarshalMap/types.UnmarshalMap`
 // does not respect custom JSON marshalers.
 
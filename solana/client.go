@@ -16,26 +16,156 @@ package solanago
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/logger"
 	ss "github.com/portto/solana-go-sdk/client"
+	"github.com/portto/solana-go-sdk/common"
+	"github.com/portto/solana-go-sdk/sysprog"
+	solPTypes "github.com/portto/solana-go-sdk/types"
 )
 
+// nonceAuthorityOffset is the offset of the authority in the data of a
+// nonce account, after its version and state.
+const nonceAuthorityOffset = 8
+
+// simulateAttempts is how many times Simulate reads the accounts and
+// simulates a transaction before it gives up on reading both at the
+// same slot.
+const simulateAttempts = 3
+
+// Client is a JSON-RPC client of one or more upstream endpoints.
+// Requests fail over between endpoints and are retried with
+// exponential backoff, see ClientConfig.
 type Client struct {
-	Rpc *ss.Client
+	httpClient *http.Client
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	maxSlotLag uint64
+	commitment string
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewClient creates a Client that from the provided url and params.
+// NewClient creates a Client of url, a comma separated list of
+// endpoints, with the default retries, backoff and health checks.
 func NewClient(url string) (*Client, error) {
-	rpc := ss.NewClient(url)
+	return NewClientWithConfig(ClientConfig{
+		URLs:    SplitURLs(url),
+		Retries: DefaultRetries,
+	})
+}
+
+// NewClientWithConfig creates a Client of the endpoints of config. The
+// health and slot of the endpoints are checked in the background.
+func NewClientWithConfig(config ClientConfig) (*Client, error) {
+	if len(config.URLs) == 0 {
+		return nil, errors.New("no endpoint url")
+	}
+	ec := &Client{
+		httpClient: &http.Client{},
+		retries:    config.Retries,
+		minBackoff: config.Backoff,
+		maxBackoff: config.MaxBackoff,
+		maxSlotLag: config.MaxSlotLag,
+		commitment: config.Commitment,
+		stop:       make(chan struct{}),
+	}
+	if ec.minBackoff == 0 {
+		ec.minBackoff = DefaultBackoff
+	}
+	if ec.maxBackoff == 0 {
+		ec.maxBackoff = DefaultMaxBackoff
+	}
+	if ec.maxSlotLag == 0 {
+		ec.maxSlotLag = DefaultMaxSlotLag
+	}
+	for _, url := range config.URLs {
+		ec.endpoints = append(ec.endpoints, &endpoint{url: url, name: endpointName(url), healthy: true})
+	}
 
-	return &Client{Rpc: rpc}, nil
+	interval := config.HealthCheckInterval
+	if interval == 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	if interval > 0 {
+		go ec.healthCheck(interval)
+	}
+	return ec, nil
 }
 
-// Close shuts down the RPC client connection.
+// Close stops the health checks of the endpoints.
 func (ec *Client) Close() {
+	ec.closeOnce.Do(func() {
+		close(ec.stop)
+	})
+}
+
+type commitmentKey struct{}
+
+// WithCommitment returns a copy of ctx whose requests read at
+// commitment instead of the default commitment of the client.
+func WithCommitment(ctx context.Context, commitment string) context.Context {
+	return context.WithValue(ctx, commitmentKey{}, commitment)
+}
+
+// Commitment returns the commitment requests made with ctx read at. It
+// is empty when the node default applies.
+func (ec *Client) Commitment(ctx context.Context) string {
+	if commitment, ok := ctx.Value(commitmentKey{}).(string); ok && len(commitment) > 0 {
+		return commitment
+	}
+	return ec.commitment
+}
+
+// BlockCommitment is Commitment for methods reading blocks and
+// transactions, which read processed data at confirmed.
+func (ec *Client) BlockCommitment(ctx context.Context) string {
+	if commitment := ec.Commitment(ctx); commitment != ProcessedCommitment {
+		return commitment
+	}
+	return ConfirmedCommitment
+}
+
+// withConfig appends the configuration object config of a request,
+// with the commitment of ctx, to params unless it is empty.
+func (ec *Client) withConfig(
+	ctx context.Context,
+	params []interface{},
+	config map[string]interface{},
+) []interface{} {
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	if commitment := ec.Commitment(ctx); len(commitment) > 0 {
+		config[CommitmentKey] = commitment
+	}
+	if len(config) == 0 {
+		return params
+	}
+	return append(params, config)
+}
+
+// blockConfig returns the configuration object of a request for a
+// block or transaction in encoding at the block commitment of ctx.
+func (ec *Client) blockConfig(ctx context.Context, encoding string) map[string]interface{} {
+	config := map[string]interface{}{"encoding": encoding}
+	if commitment := ec.BlockCommitment(ctx); len(commitment) > 0 {
+		config[CommitmentKey] = commitment
+	}
+	return config
 }
 
 // Status returns geth status information
@@ -47,13 +177,26 @@ func (ec *Client) Status(ctx context.Context) (
 	*RosettaTypes.BlockIdentifier,
 	error,
 ) {
-	genesis, _ := ec.Rpc.GetGenesisHash(ctx)
-	index, _ := ec.Rpc.GetFirstAvailableBlock(ctx)
+	// The status is reported with what the node could answer.
+	log := logger.FromContext(ctx)
+	warn := func(msg string, err error) {
+		if err != nil {
+			log.Warn(msg, "error", err)
+		}
+	}
+	genesis, err := ec.GetGenesisHash(ctx)
+	warn("unable to get genesis hash", err)
+	index, err := ec.GetFirstAvailableBlock(ctx)
+	warn("unable to get first available block", err)
 
-	bhash, _ := ec.Rpc.GetRecentBlockhash(ctx)
-	slot, _ := ec.Rpc.GetSlot(ctx)
-	slotTime, _ := ec.Rpc.GetBlockTime(ctx, uint64(slot))
-	clusterNodes, _ := ec.Rpc.GetClusterNodes(ctx)
+	bhash, err := ec.GetRecentBlockhash(ctx)
+	warn("unable to get recent blockhash", err)
+	slot, err := ec.GetSlot(ctx)
+	warn("unable to get slot", err)
+	slotTime, err := ec.GetBlockTime(ctx, uint64(slot))
+	warn("unable to get block time", err)
+	clusterNodes, err := ec.GetClusterNodes(ctx)
+	warn("unable to get cluster nodes", err)
 	var peers []*RosettaTypes.Peer
 	for _, k := range clusterNodes {
 		peers = append(peers, &RosettaTypes.Peer{PeerID: k.Pubkey})
@@ -75,11 +218,18 @@ func (ec *Client) BlockTransaction(
 	ctx context.Context,
 	blockTransactionRequest *RosettaTypes.BlockTransactionRequest,
 ) (*RosettaTypes.Transaction, error) {
-	tx, err := ec.Rpc.GetConfirmedTransactionParsed(ctx, blockTransactionRequest.TransactionIdentifier.Hash)
+	var tx ss.GetConfirmedTransactionParsedResponse
+	err := ec.callParsed(ctx, "getConfirmedTransaction", []interface{}{
+		blockTransactionRequest.TransactionIdentifier.Hash,
+		ec.blockConfig(ctx, "jsonParsed"),
+	}, &tx)
 	if err != nil {
 		return nil, err
 	}
 	rosTx := ToRosTx(tx.Transaction)
+	if commitment := ec.BlockCommitment(ctx); len(commitment) > 0 {
+		rosTx.Metadata[CommitmentKey] = commitment
+	}
 	return &rosTx, nil
 }
 
@@ -92,10 +242,18 @@ func (ec *Client) Block(
 ) (*RosettaTypes.Block, error) {
 	if blockIdentifier != nil {
 		if blockIdentifier.Index != nil {
-			blockResponse, err := ec.Rpc.GetConfirmedBlockParsed(ctx, uint64(*blockIdentifier.Index))
+			var blockResponse ss.GetConfirmBlockParsedResponse
+			err := ec.callParsed(ctx, "getConfirmedBlock", []interface{}{
+				uint64(*blockIdentifier.Index),
+				ec.blockConfig(ctx, "jsonParsed"),
+			}, &blockResponse)
 			if err != nil {
 				return nil, err
 			}
+			metadata := map[string]interface{}{}
+			if commitment := ec.BlockCommitment(ctx); len(commitment) > 0 {
+				metadata[CommitmentKey] = commitment
+			}
 			return &RosettaTypes.Block{
 				BlockIdentifier: &RosettaTypes.BlockIdentifier{
 					Index: *blockIdentifier.Index,
@@ -104,7 +262,7 @@ func (ec *Client) Block(
 				ParentBlockIdentifier: &RosettaTypes.BlockIdentifier{Index: int64(blockResponse.ParentSlot), Hash: blockResponse.PreviousBlockhash},
 				Timestamp:             convertTime(uint64(blockResponse.BlockTime)),
 				Transactions:          ToRosTxs(blockResponse.Transactions),
-				Metadata:              map[string]interface{}{},
+				Metadata:              metadata,
 			}, nil
 		}
 	}
@@ -129,7 +287,7 @@ func (ec *Client) Balance(
 		return nil, fmt.Errorf("block hash balance not supported")
 	}
 
-	bal, err := ec.Rpc.GetBalance(ctx, account.Address)
+	bal, err := ec.GetBalance(ctx, account.Address)
 	if err != nil {
 		return nil, err
 	}
@@ -144,9 +302,11 @@ func (ec *Client) Balance(
 		Metadata: nil,
 	}
 
-	tokenAccs, err := ec.Rpc.GetTokenAccountsByOwner(ctx, account.Address)
-
-	if err == nil {
+	tokenAccs, err := ec.GetTokenAccountsByOwner(ctx, account.Address)
+	if err != nil {
+		// The native balance is still reported.
+		logger.FromContext(ctx).Warn("unable to get token accounts", "owner", account.Address, "error", err)
+	} else {
 		for _, tokenAcc := range tokenAccs {
 			symbol := tokenAcc.Account.Data.Parsed.Info.Mint
 			b := &RosettaTypes.Amount{
@@ -164,8 +324,17 @@ func (ec *Client) Balance(
 	if len(symbols) == 0 || Contains(symbols, Symbol) {
 		balances = append(balances, nativeBalance)
 	}
-	slot, err := ec.Rpc.GetSlot(ctx)
+	// The balances are reported at the slot, not at slot 0 if it is
+	// unknown.
+	slot, err := ec.GetSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	var metadata map[string]interface{}
+	if commitment := ec.Commitment(ctx); len(commitment) > 0 {
+		metadata = map[string]interface{}{CommitmentKey: commitment}
+	}
 	return &RosettaTypes.AccountBalanceResponse{
 
 		BlockIdentifier: &RosettaTypes.BlockIdentifier{
@@ -173,7 +342,7 @@ func (ec *Client) Balance(
 			Index: int64(slot),
 		},
 		Balances: balances,
-		Metadata: nil,
+		Metadata: metadata,
 	}, nil
 }
 
@@ -189,29 +358,415 @@ func (ec *Client) Call(
 		x = []interface{}{}
 	}
 
-	out, err := ec.Rpc.CallRequest(ctx, request.Method, x)
-
-	if err != nil {
-		return nil, fmt.Errorf("rpc call error")
-	}
-
-	res := make(map[string]interface{})
-	if _, ok := out.([]interface{}); ok {
-		res["result"] = out
-	} else {
-		res["result"] = out.(map[string]interface{})
+	var out interface{}
+	if err := ec.call(ctx, request.Method, x, &out); err != nil {
+		return nil, err
 	}
 
 	return &RosettaTypes.CallResponse{
-		Result: res,
+		Result: map[string]interface{}{"result": out},
 	}, nil
-
 }
 
 func (ec *Client) GetTokenAccountByMint(ctx context.Context, owner string, mint string) (string, error) {
-	tokenAccs, err := ec.Rpc.GetTokenAccountByMint(ctx, owner, mint)
+	tokenAccs, err := ec.GetTokenAccountsByMint(ctx, owner, mint)
 	if err != nil || len(tokenAccs) == 0 {
 		return "", fmt.Errorf("No Token Account Found")
 	}
 	return tokenAccs[0].Pubkey, nil
 }
+
+// GetGenesisHash returns the hash of the genesis block.
+func (ec *Client) GetGenesisHash(ctx context.Context) (string, error) {
+	var hash string
+	err := ec.call(ctx, "getGenesisHash", []interface{}{}, &hash)
+	return hash, err
+}
+
+// GetFirstAvailableBlock returns the slot of the lowest block the
+// node has not purged.
+func (ec *Client) GetFirstAvailableBlock(ctx context.Context) (uint64, error) {
+	var slot uint64
+	err := ec.call(ctx, "getFirstAvailableBlock", []interface{}{}, &slot)
+	return slot, err
+}
+
+// GetRecentBlockhash returns a recent blockhash and its fee
+// calculator.
+func (ec *Client) GetRecentBlockhash(ctx context.Context) (ss.GetRecentBlockHashResponse, error) {
+	var res struct {
+		Value ss.GetRecentBlockHashResponse `json:"value"`
+	}
+	err := ec.call(ctx, "getRecentBlockhash", ec.withConfig(ctx, []interface{}{}, nil), &res)
+	return res.Value, err
+}
+
+// GetSlot returns the current slot of the node.
+func (ec *Client) GetSlot(ctx context.Context) (uint64, error) {
+	var slot uint64
+	err := ec.call(ctx, "getSlot", ec.withConfig(ctx, []interface{}{}, nil), &slot)
+	return slot, err
+}
+
+// GetBlockTime returns the estimated production time of the block
+// at slot.
+func (ec *Client) GetBlockTime(ctx context.Context, slot uint64) (int64, error) {
+	var blockTime int64
+	err := ec.call(ctx, "getBlockTime", []interface{}{slot}, &blockTime)
+	return blockTime, err
+}
+
+// GetClusterNodes returns the nodes of the cluster.
+func (ec *Client) GetClusterNodes(ctx context.Context) ([]ss.GetClusterNodesResponse, error) {
+	var nodes []ss.GetClusterNodesResponse
+	err := ec.call(ctx, "getClusterNodes", []interface{}{}, &nodes)
+	return nodes, err
+}
+
+// GetTokenAccountsByOwner returns the jsonParsed token accounts of
+// owner.
+func (ec *Client) GetTokenAccountsByOwner(ctx context.Context, owner string) ([]ss.Accounts, error) {
+	return ec.getTokenAccountsByOwner(ctx, owner, map[string]interface{}{"programId": common.TokenProgramID.ToBase58()})
+}
+
+// GetTokenAccountsByMint returns the jsonParsed token accounts of
+// owner for mint.
+func (ec *Client) GetTokenAccountsByMint(ctx context.Context, owner string, mint string) ([]ss.Accounts, error) {
+	return ec.getTokenAccountsByOwner(ctx, owner, map[string]interface{}{"mint": mint})
+}
+
+func (ec *Client) getTokenAccountsByOwner(
+	ctx context.Context,
+	owner string,
+	filter map[string]interface{},
+) ([]ss.Accounts, error) {
+	var res struct {
+		Value []ss.Accounts `json:"value"`
+	}
+	err := ec.call(ctx, "getTokenAccountsByOwner", ec.withConfig(ctx, []interface{}{
+		owner,
+		filter,
+	}, map[string]interface{}{"encoding": "jsonParsed"}), &res)
+	return res.Value, err
+}
+
+// GetBalance returns the lamports of address.
+func (ec *Client) GetBalance(ctx context.Context, address string) (uint64, error) {
+	var res struct {
+		Value uint64 `json:"value"`
+	}
+	err := ec.call(ctx, "getBalance", ec.withConfig(ctx, []interface{}{address}, nil), &res)
+	return res.Value, err
+}
+
+// GetBalanceAt returns the lamports of address and the slot the node
+// read them at. A non-zero minSlot makes the node refuse to answer
+// before it reached that slot.
+func (ec *Client) GetBalanceAt(ctx context.Context, address string, minSlot uint64) (uint64, uint64, error) {
+	var res struct {
+		Context struct {
+			Slot uint64 `json:"slot"`
+		} `json:"context"`
+		Value uint64 `json:"value"`
+	}
+	err := ec.call(ctx, "getBalance", ec.withConfig(ctx, []interface{}{address}, contextConfig(minSlot)), &res)
+	if err != nil {
+		return 0, 0, err
+	}
+	return res.Value, res.Context.Slot, nil
+}
+
+// GetTokenBalanceAt returns the amount and decimals of the token
+// account and the slot the node read them at, like GetBalanceAt.
+func (ec *Client) GetTokenBalanceAt(ctx context.Context, account string, minSlot uint64) (uint64, uint8, uint64, error) {
+	var res struct {
+		Context struct {
+			Slot uint64 `json:"slot"`
+		} `json:"context"`
+		Value OpMetaTokenAmount `json:"value"`
+	}
+	err := ec.call(ctx, "getTokenAccountBalance", ec.withConfig(ctx, []interface{}{account}, contextConfig(minSlot)), &res)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	amount, err := strconv.ParseUint(res.Value.Amount, 10, 64)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return amount, uint8(res.Value.Decimals), res.Context.Slot, nil
+}
+
+// GetNonceAccounts returns the durable nonce accounts controlled by
+// authority.
+func (ec *Client) GetNonceAccounts(ctx context.Context, authority string) ([]string, error) {
+	var res []struct {
+		Pubkey string `json:"pubkey"`
+	}
+	err := ec.call(ctx, "getProgramAccounts", ec.withConfig(ctx, []interface{}{
+		common.SystemProgramID.ToBase58(),
+	}, map[string]interface{}{
+		"encoding":  "base64",
+		"dataSlice": map[string]interface{}{"offset": 0, "length": 0},
+		"filters": []interface{}{
+			map[string]interface{}{"dataSize": sysprog.NonceAccountSize},
+			map[string]interface{}{"memcmp": map[string]interface{}{
+				"offset": nonceAuthorityOffset,
+				"bytes":  authority,
+			}},
+		},
+	}), &res)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []string
+	for _, v := range res {
+		accounts = append(accounts, v.Pubkey)
+	}
+	return accounts, nil
+}
+
+// GetSignatureStatuses returns the statuses of signatures. The status
+// of a signature the node does not know is nil.
+func (ec *Client) GetSignatureStatuses(ctx context.Context, signatures []string) ([]*SignatureStatus, error) {
+	var res struct {
+		Value []*SignatureStatus `json:"value"`
+	}
+	err := ec.call(ctx, "getSignatureStatuses", []interface{}{signatures}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Value, nil
+}
+
+// SendTransaction sends the serialized transaction rawTx base64
+// encoded and returns its signature. Preflight checks run at
+// preflightCommitment, the node default if empty, unless skipPreflight
+// is set.
+func (ec *Client) SendTransaction(
+	ctx context.Context,
+	rawTx []byte,
+	skipPreflight bool,
+	preflightCommitment string,
+) (string, error) {
+	config := map[string]interface{}{
+		"encoding":      "base64",
+		"skipPreflight": skipPreflight,
+	}
+	if len(preflightCommitment) > 0 {
+		config["preflightCommitment"] = preflightCommitment
+	}
+	var signature string
+	err := ec.call(ctx, "sendTransaction", []interface{}{
+		base64.StdEncoding.EncodeToString(rawTx),
+		config,
+	}, &signature)
+	if err != nil {
+		return "", err
+	}
+	return signature, nil
+}
+
+// IsBlockhashValid returns true if transactions using blockhash can
+// still be processed.
+func (ec *Client) IsBlockhashValid(ctx context.Context, blockhash string) (bool, error) {
+	var res struct {
+		Value bool `json:"value"`
+	}
+	err := ec.call(ctx, "isBlockhashValid", ec.withConfig(ctx, []interface{}{blockhash}, nil), &res)
+	if err != nil {
+		return false, err
+	}
+	return res.Value, nil
+}
+
+// GetTransactionOutcome returns the slot, error and fee of the
+// confirmed transaction signature. It returns nil if the node does not
+// know the transaction.
+func (ec *Client) GetTransactionOutcome(ctx context.Context, signature string) (*TransactionOutcome, error) {
+	var res *struct {
+		Slot uint64 `json:"slot"`
+		Meta *struct {
+			Err interface{} `json:"err"`
+			Fee uint64      `json:"fee"`
+		} `json:"meta"`
+	}
+	err := ec.call(ctx, "getConfirmedTransaction", []interface{}{signature, ec.blockConfig(ctx, "json")}, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, nil
+	}
+	outcome := &TransactionOutcome{Slot: res.Slot}
+	if res.Meta != nil {
+		outcome.Err = res.Meta.Err
+		outcome.Fee = res.Meta.Fee
+	}
+	return outcome, nil
+}
+
+func contextConfig(minSlot uint64) map[string]interface{} {
+	config := map[string]interface{}{}
+	if minSlot > 0 {
+		config["minContextSlot"] = minSlot
+	}
+	return config
+}
+
+// GetAccountInfo returns the jsonParsed account at address. It returns
+// nil if the account does not exist.
+func (ec *Client) GetAccountInfo(ctx context.Context, address string) (*AccountInfo, error) {
+	var res struct {
+		Value *rpcAccount `json:"value"`
+	}
+	err := ec.call(ctx, "getAccountInfo", ec.withConfig(ctx, []interface{}{
+		address,
+	}, map[string]interface{}{"encoding": "jsonParsed"}), &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Value.toAccountInfo()
+}
+
+// GetMultipleAccounts returns the jsonParsed accounts at addresses. Accounts
+// that do not exist are nil.
+func (ec *Client) GetMultipleAccounts(ctx context.Context, addresses []string) ([]*AccountInfo, error) {
+	accounts, _, err := ec.getMultipleAccounts(ctx, addresses, 0)
+	return accounts, err
+}
+
+// getMultipleAccounts is GetMultipleAccounts read at minContextSlot or
+// later, if set, which also returns the slot the accounts were read
+// at.
+func (ec *Client) getMultipleAccounts(
+	ctx context.Context,
+	addresses []string,
+	minContextSlot uint64,
+) ([]*AccountInfo, uint64, error) {
+	config := map[string]interface{}{"encoding": "jsonParsed"}
+	if minContextSlot > 0 {
+		config["minContextSlot"] = minContextSlot
+	}
+	var res struct {
+		Context struct {
+			Slot uint64 `json:"slot"`
+		} `json:"context"`
+		Value []*rpcAccount `json:"value"`
+	}
+	err := ec.call(ctx, "getMultipleAccounts", ec.withConfig(ctx, []interface{}{
+		addresses,
+	}, config), &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	accounts, err := toAccountInfos(res.Value)
+	return accounts, res.Context.Slot, err
+}
+
+// Simulate runs simulateTransaction for tx and returns its result along
+// with the state of every account of the transaction before and after
+// the simulation. Both states are read at the same slot: the simulation
+// runs at the slot the accounts were read at or later, and the accounts
+// are read again at the slot of the simulation until they match, at
+// most simulateAttempts times.
+func (ec *Client) Simulate(
+	ctx context.Context,
+	tx solPTypes.Transaction,
+	sigVerify bool,
+) (*Simulation, error) {
+	rawTx, err := tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, v := range tx.Message.Accounts {
+		addresses = append(addresses, v.ToBase58())
+	}
+
+	var minContextSlot uint64
+	for attempt := 1; ; attempt++ {
+		pre, slot, err := ec.getMultipleAccounts(ctx, addresses, minContextSlot)
+		if err != nil {
+			return nil, err
+		}
+		sim, err := ec.simulate(ctx, rawTx, addresses, sigVerify, slot)
+		if err != nil {
+			return nil, err
+		}
+		if sim.Slot == slot {
+			sim.Pre = pre
+			return sim, nil
+		}
+		if attempt >= simulateAttempts {
+			return nil, fmt.Errorf(
+				"%w: simulated at slot %d, accounts read at slot %d",
+				ErrSlotMismatch,
+				sim.Slot,
+				slot,
+			)
+		}
+		minContextSlot = sim.Slot
+	}
+}
+
+// simulate runs simulateTransaction for rawTx at minContextSlot or
+// later and returns its result with the state of the accounts at
+// addresses after the simulation.
+func (ec *Client) simulate(
+	ctx context.Context,
+	rawTx []byte,
+	addresses []string,
+	sigVerify bool,
+	minContextSlot uint64,
+) (*Simulation, error) {
+	var res struct {
+		Context struct {
+			Slot uint64 `json:"slot"`
+		} `json:"context"`
+		Value struct {
+			Err           interface{}   `json:"err"`
+			Logs          []string      `json:"logs"`
+			Accounts      []*rpcAccount `json:"accounts"`
+			UnitsConsumed uint64        `json:"unitsConsumed"`
+		} `json:"value"`
+	}
+	err := ec.call(ctx, "simulateTransaction", ec.withConfig(ctx, []interface{}{
+		base64.StdEncoding.EncodeToString(rawTx),
+	}, map[string]interface{}{
+		"encoding":       "base64",
+		"sigVerify":      sigVerify,
+		"minContextSlot": minContextSlot,
+		"accounts": map[string]interface{}{
+			"encoding":  "jsonParsed",
+			"addresses": addresses,
+		},
+	}), &res)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := toAccountInfos(res.Value.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulation{
+		Err:           res.Value.Err,
+		Logs:          res.Value.Logs,
+		UnitsConsumed: res.Value.UnitsConsumed,
+		Slot:          res.Context.Slot,
+		Addresses:     addresses,
+		Post:          post,
+	}, nil
+}
+
+func toAccountInfos(accounts []*rpcAccount) ([]*AccountInfo, error) {
+	infos := make([]*AccountInfo, len(accounts))
+	for i, v := range accounts {
+		info, err := v.toAccountInfo()
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
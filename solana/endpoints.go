@@ -0,0 +1,204 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solanago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imerkle/rosetta-solana-go/logger"
+)
+
+const (
+	// DefaultRetries is how many times a request failing with a
+	// retriable error is retried.
+	DefaultRetries = 3
+
+	// DefaultBackoff is the wait before the first retry of a request.
+	// It doubles on every retry up to DefaultMaxBackoff.
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 2 * time.Second
+
+	// DefaultMaxSlotLag is how many slots an endpoint can be behind the
+	// most advanced one before requests prefer other endpoints.
+	DefaultMaxSlotLag = 150
+
+	// DefaultHealthCheckInterval is how often the health and slot of
+	// the endpoints are checked.
+	DefaultHealthCheckInterval = 10 * time.Second
+)
+
+// ClientConfig configures the upstream endpoints of a Client. Zero
+// backoffs, slot lag and health check interval take their defaults; a
+// negative health check interval disables health checks. Commitment is
+// the default commitment of reads, the one of the node if empty.
+type ClientConfig struct {
+	URLs                []string
+	Commitment          string
+	Retries             int
+	Backoff             time.Duration
+	MaxBackoff          time.Duration
+	MaxSlotLag          uint64
+	HealthCheckInterval time.Duration
+}
+
+// endpoint is an upstream node of a Client. A request failing with a
+// retriable error marks it unhealthy until its next health check. name
+// identifies it in metrics.
+type endpoint struct {
+	url     string
+	name    string
+	healthy bool
+	slot    uint64
+}
+
+// SplitURLs returns the endpoint urls of a comma separated list.
+func SplitURLs(urls string) []string {
+	var split []string
+	for _, v := range strings.Split(urls, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			split = append(split, v)
+		}
+	}
+	return split
+}
+
+// endpointOrder returns the endpoints in the order a request tries
+// them: healthy endpoints at most maxSlotLag slots behind the most
+// advanced one first, then lagging ones, then unhealthy ones, each in
+// configured order.
+func (ec *Client) endpointOrder() []*endpoint {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	var highest uint64
+	for _, e := range ec.endpoints {
+		if e.healthy && e.slot > highest {
+			highest = e.slot
+		}
+	}
+	var current, lagging, unhealthy []*endpoint
+	for _, e := range ec.endpoints {
+		switch {
+		case !e.healthy:
+			unhealthy = append(unhealthy, e)
+		case e.slot+ec.maxSlotLag < highest:
+			lagging = append(lagging, e)
+		default:
+			current = append(current, e)
+		}
+	}
+	return append(append(current, lagging...), unhealthy...)
+}
+
+// markFailed marks e unhealthy.
+func (ec *Client) markFailed(e *endpoint) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	e.healthy = false
+	ec.observeEndpoints()
+}
+
+// checkEndpoints updates the health and slot of every endpoint.
+func (ec *Client) checkEndpoints(ctx context.Context) {
+	log := logger.FromContext(ctx)
+	body, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: "getSlot", Params: []interface{}{}})
+	for _, e := range ec.endpoints {
+		var slot uint64
+		raw, err := ec.post(ctx, e.url, "getSlot", body)
+		if err == nil {
+			err = json.Unmarshal(raw, &slot)
+		}
+		if err != nil {
+			log.Warn("health check failed", "rpc_endpoint", e.name, "error", err)
+		} else {
+			log.Debug("health check", "rpc_endpoint", e.name, "slot", slot)
+		}
+		ec.mu.Lock()
+		e.healthy = err == nil
+		if err == nil {
+			e.slot = slot
+		}
+		ec.mu.Unlock()
+	}
+	ec.mu.Lock()
+	ec.observeEndpoints()
+	ec.mu.Unlock()
+}
+
+// healthCheck checks the endpoints every interval until the client is
+// closed.
+func (ec *Client) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		ec.checkEndpoints(ctx)
+		cancel()
+		select {
+		case <-ec.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backoff waits before retry attempt of a request, or until ctx is
+// done.
+func (ec *Client) backoff(ctx context.Context, attempt int) error {
+	wait := ec.minBackoff
+	for i := 0; i < attempt && wait < ec.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > ec.maxBackoff {
+		wait = ec.maxBackoff
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retriable returns true if a request failing with err may succeed on
+// another attempt: the endpoint could not be reached, answered with an
+// HTTP 5xx or 429 status, is behind or rate limits requests. Responses
+// that cannot be decoded are not retried.
+func retriable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var transportErr *transportError
+	if errors.As(err, &transportErr) {
+		return true
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500 || statusErr.code == http.StatusTooManyRequests
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		class := ClassifyError(err)
+		return class == ErrNodeBehind || class == ErrRateLimited
+	}
+	return false
+}
@@ -14,11 +14,142 @@
 
 package solanago
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
 
 // Client errors
 var (
 	ErrCallParametersInvalid = errors.New("call parameters invalid")
 	ErrCallOutputMarshal     = errors.New("call output marshal")
 	ErrCallMethodInvalid     = errors.New("call method invalid")
+	ErrSlotMismatch          = errors.New("slot mismatch")
+)
+
+// Node errors, the classes ClassifyError sorts the errors of the node
+// into.
+var (
+	ErrBlockhashNotFound       = errors.New("blockhash not found")
+	ErrInsufficientFundsForFee = errors.New("insufficient funds for fee")
+	ErrAccountNotFound         = errors.New("account not found")
+	ErrSlotSkipped             = errors.New("slot skipped")
+	ErrNodeBehind              = errors.New("node behind")
+	ErrRateLimited             = errors.New("rate limited")
+	ErrMethodNotFound          = errors.New("method not found")
+	ErrInvalidParams           = errors.New("invalid params")
+	ErrTransactionFailed       = errors.New("transaction failed")
+)
+
+// JSON-RPC error codes of the node.
+const (
+	rpcMethodNotFound                = -32601
+	rpcInvalidParams                 = -32602
+	rpcPreflightFailure              = -32002
+	rpcBlockNotAvailable             = -32004
+	rpcNodeUnhealthy                 = -32005
+	rpcSlotSkipped                   = -32007
+	rpcLongTermStorageSlotSkipped    = -32009
+	rpcBlockStatusNotAvailableYet    = -32014
+	rpcMinContextSlotNotReached      = -32016
+	rpcTooManyRequests               = 429
+	preflightBlockhashNotFound       = "BlockhashNotFound"
+	preflightInsufficientFundsForFee = "InsufficientFundsForFee"
+	preflightAccountNotFound         = "AccountNotFound"
 )
+
+// ClassifyError returns the node error class of err, or nil if err is
+// not a known error of the node. Errors of the node are classified by
+// their JSON-RPC code and, for failed preflight simulations, by the
+// transaction error. Errors passed on by the SDK lost their code and
+// are classified by their message.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, class := range []error{
+		ErrBlockhashNotFound, ErrInsufficientFundsForFee, ErrAccountNotFound, ErrSlotSkipped,
+		ErrNodeBehind, ErrRateLimited, ErrMethodNotFound, ErrInvalidParams, ErrTransactionFailed,
+	} {
+		if errors.Is(err, class) {
+			return class
+		}
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case rpcMethodNotFound:
+			return ErrMethodNotFound
+		case rpcInvalidParams:
+			return ErrInvalidParams
+		case rpcPreflightFailure:
+			if class := classifyTransactionError(rpcErr.TransactionError()); class != nil {
+				return class
+			}
+		case rpcBlockNotAvailable, rpcNodeUnhealthy, rpcBlockStatusNotAvailableYet, rpcMinContextSlotNotReached:
+			return ErrNodeBehind
+		case rpcSlotSkipped, rpcLongTermStorageSlotSkipped:
+			return ErrSlotSkipped
+		case rpcTooManyRequests:
+			return ErrRateLimited
+		}
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "blockhash not found"):
+		return ErrBlockhashNotFound
+	case strings.Contains(message, "insufficient funds for fee"):
+		return ErrInsufficientFundsForFee
+	case strings.Contains(message, "attempt to debit an account but found no record of a prior credit"),
+		strings.Contains(message, "could not find account"):
+		return ErrAccountNotFound
+	case strings.Contains(message, "was skipped"):
+		return ErrSlotSkipped
+	case strings.Contains(message, "node is behind"),
+		strings.Contains(message, "node is unhealthy"),
+		strings.Contains(message, "minimum context slot has not been reached"):
+		return ErrNodeBehind
+	case strings.Contains(message, "too many requests"),
+		strings.Contains(message, "rate limit"),
+		strings.Contains(message, "status code: 429"):
+		return ErrRateLimited
+	case strings.Contains(message, "invalid param"):
+		return ErrInvalidParams
+	}
+	if rpcErr != nil && rpcErr.Code == rpcPreflightFailure {
+		return ErrTransactionFailed
+	}
+	return nil
+}
+
+// classifyTransactionError returns the node error class of the error
+// of a transaction as reported by the node, or nil.
+func classifyTransactionError(txErr interface{}) error {
+	switch txErr {
+	case nil:
+		return nil
+	case preflightBlockhashNotFound:
+		return ErrBlockhashNotFound
+	case preflightInsufficientFundsForFee:
+		return ErrInsufficientFundsForFee
+	case preflightAccountNotFound:
+		return ErrAccountNotFound
+	default:
+		return ErrTransactionFailed
+	}
+}
+
+// TransactionError returns the transaction error of a failed preflight
+// simulation, or nil.
+func (e *RPCError) TransactionError() interface{} {
+	var data struct {
+		Err interface{} `json:"err"`
+	}
+	if len(e.Data) == 0 || json.Unmarshal(e.Data, &data) != nil {
+		return nil
+	}
+	return data.Err
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solanago
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "solana_rpc_requests_total",
+		Help: "JSON-RPC requests sent to the upstream endpoints, per method and endpoint.",
+	}, []string{"method", "endpoint"})
+	rpcFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "solana_rpc_request_failures_total",
+		Help: "JSON-RPC requests that failed, per method, endpoint and kind of error.",
+	}, []string{"method", "endpoint", "error"})
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "solana_rpc_request_duration_seconds",
+		Help:    "Latency of the JSON-RPC requests, retries included, per method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	endpointHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "solana_rpc_endpoint_healthy",
+		Help: "Whether the upstream endpoint is healthy.",
+	}, []string{"endpoint"})
+	endpointSlot = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "solana_rpc_endpoint_slot",
+		Help: "Slot of the upstream endpoint at its last health check.",
+	}, []string{"endpoint"})
+	endpointSlotLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "solana_rpc_endpoint_slot_lag",
+		Help: "Slots the upstream endpoint is behind the most advanced one.",
+	}, []string{"endpoint"})
+)
+
+// endpointName returns the name of the endpoint of rawurl in metrics:
+// its host, without the credentials or API keys its url may hold.
+func endpointName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || len(u.Host) == 0 {
+		return "unknown"
+	}
+	return u.Host
+}
+
+// errorKind returns the kind of a failed request in metrics.
+func errorKind(ctx context.Context, err error) string {
+	var rpcErr *RPCError
+	switch {
+	case ctx.Err() != nil:
+		return "canceled"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case !errors.As(err, &rpcErr):
+		return "transport"
+	}
+	switch ClassifyError(err) {
+	case ErrNodeBehind:
+		return "node_behind"
+	case ErrRateLimited:
+		return "rate_limited"
+	default:
+		return "rpc"
+	}
+}
+
+// observeRequest records an attempt of a request of method to e.
+func observeRequest(ctx context.Context, method string, e *endpoint, err error) {
+	rpcRequests.WithLabelValues(method, e.name).Inc()
+	if err != nil {
+		rpcFailures.WithLabelValues(method, e.name, errorKind(ctx, err)).Inc()
+	}
+}
+
+// observeCall records the latency of a request of method started at
+// start.
+func observeCall(method string, start time.Time) {
+	rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// observeEndpoints records the health, slot and slot lag of the
+// endpoints. It must be called with mu held.
+func (ec *Client) observeEndpoints() {
+	var highest uint64
+	for _, e := range ec.endpoints {
+		if e.healthy && e.slot > highest {
+			highest = e.slot
+		}
+	}
+	for _, e := range ec.endpoints {
+		healthy := 0.0
+		if e.healthy {
+			healthy = 1
+		}
+		endpointHealthy.WithLabelValues(e.name).Set(healthy)
+		endpointSlot.WithLabelValues(e.name).Set(float64(e.slot))
+		var lag uint64
+		if e.slot < highest {
+			lag = highest - e.slot
+		}
+		endpointSlotLag.WithLabelValues(e.name).Set(float64(lag))
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operations
+
+import (
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+// memo builds the memo instruction of text. It names no signers, so
+// it adds no signature to the transaction.
+func memo(text string) solPTypes.Instruction {
+	return solPTypes.Instruction{
+		ProgramID: p(solanago.MemoProgramID),
+		Accounts:  []solPTypes.AccountMeta{},
+		Data:      []byte(text),
+	}
+}
@@ -1,3 +1,17 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
 package operations
 
 import (
@@ -25,6 +39,20 @@ type SplTokenOperationMetadata struct {
 
 	SourceToken      string `json:"source_token,omitempty"`
 	DestinationToken string `json:"destination_token,omitempty"`
+
+	// AuthorityType is one of solanago.AuthorityTypes. An empty
+	// NewAuthority removes the authority.
+	AuthorityType string `json:"authority_type,omitempty"`
+	NewAuthority  string `json:"new_authority,omitempty"`
+
+	// Signers are the signers of a multisig authority. M is the number
+	// of them a new multisig requires.
+	Signers []string `json:"signers,omitempty"`
+	M       uint8    `json:"m,omitempty"`
+
+	// Memo adds a memo instruction after the instructions of the
+	// operation.
+	Memo string `json:"memo,omitempty"`
 }
 
 func (x *SplTokenOperationMetadata) SetMeta(op *types.Operation, splTokenAccsMap map[string]solanago.SplAccounts) {
@@ -59,6 +87,9 @@ func (x *SplTokenOperationMetadata) ToInstructions(opType string) []solPTypes.In
 	case solanago.SplToken__InitializeMint:
 		ins = append(ins, tokenprog.InitializeMint(x.Decimals, p(x.Mint), p(x.Source), p(x.Authority)))
 		break
+	case solanago.SplToken__InitializeMultisig:
+		ins = append(ins, initializeMultisig(p(x.Source), x.signers(), x.M))
+		break
 	case solanago.SplToken__InitializeAccount:
 		ins = append(ins, tokenprog.InitializeAccount(p(x.Destination), p(x.Mint), p(x.Source)))
 
@@ -73,54 +104,199 @@ func (x *SplTokenOperationMetadata) ToInstructions(opType string) []solPTypes.In
 
 		break
 	case solanago.SplToken__Approve:
-		ins = append(ins, tokenprog.Approve(p(x.Source), p(x.Destination), p(x.Authority), []common.PublicKey{}, x.Amount))
+		ins = append(ins, tokenprog.Approve(p(x.Source), p(x.Destination), p(x.Authority), x.signers(), x.Amount))
 		break
 	case solanago.SplToken__Revoke:
-		ins = append(ins, tokenprog.Revoke(p(x.Source), p(x.Authority), []common.PublicKey{}))
+		ins = append(ins, tokenprog.Revoke(p(x.Source), p(x.Authority), x.signers()))
+		break
+	case solanago.SplToken__MintTo:
+		ins = append(ins, tokenprog.MintTo(p(x.Mint), p(x.Source), p(x.Authority), x.signers(), x.Amount))
 		break
-	case solanago.SplToken_MintTo:
-		ins = append(ins, tokenprog.MintTo(p(x.Mint), p(x.Source), p(x.Authority), []common.PublicKey{}, x.Amount))
+	case solanago.SplToken__MintToChecked:
+		ins = append(ins, tokenprog.MintToChecked(p(x.Mint), p(x.Source), p(x.Authority), x.signers(), x.Amount, x.Decimals))
 		break
-	case solanago.SplToken_Burn:
-		ins = append(ins, tokenprog.Burn(p(x.Source), p(x.Mint), p(x.Authority), []common.PublicKey{}, x.Amount))
+	case solanago.SplToken__Burn:
+		ins = append(ins, tokenprog.Burn(p(x.Source), p(x.Mint), p(x.Authority), x.signers(), x.Amount))
 		break
-	case solanago.SplToken_CloseAccount:
-		ins = append(ins, tokenprog.CloseAccount(p(x.Source), p(x.Destination), p(x.Authority), []common.PublicKey{}))
+	case solanago.SplToken__BurnChecked:
+		ins = append(ins, tokenprog.BurnChecked(p(x.Source), p(x.Mint), p(x.Authority), x.signers(), x.Amount, x.Decimals))
 		break
-	case solanago.SplToken_FreezeAccount:
-		ins = append(ins, tokenprog.ThawAccount(p(x.Source), p(x.Mint), p(x.Authority), []common.PublicKey{}))
+	case solanago.SplToken__CloseAccount:
+		ins = append(ins, tokenprog.CloseAccount(p(x.Source), p(x.Destination), p(x.Authority), x.signers()))
+		break
+	case solanago.SplToken__FreezeAccount:
+		ins = append(ins, tokenprog.FreezeAccount(p(x.Source), p(x.Mint), p(x.Authority), x.signers()))
+		break
+	case solanago.SplToken__ThawAccount:
+		ins = append(ins, tokenprog.ThawAccount(p(x.Source), p(x.Mint), p(x.Authority), x.signers()))
+		break
+	case solanago.SplToken__SetAuthority:
+		authorityType := indexOf(solanago.AuthorityTypes, x.AuthorityType)
+		if authorityType < 0 {
+			break
+		}
+		ins = append(ins, setAuthority(p(x.Source), p(x.Authority), x.signers(), uint8(authorityType), x.NewAuthority))
+		break
+	case solanago.SplToken__SyncNative:
+		ins = append(ins, syncNative(p(x.Source)))
+		break
+	case solanago.SplToken__WrapSol:
+		account := x.DestinationToken
+		if account == "" {
+			in := assotokenprog.CreateAssociatedTokenAccount(p(x.funder()), p(x.Source), p(solanago.NativeMint))
+			account = in.Accounts[1].PubKey.ToBase58()
+			ins = append(ins, in)
+		}
+		ins = append(ins, sysprog.Transfer(p(x.Source), p(account), x.Amount))
+		ins = append(ins, syncNative(p(account)))
+		break
+	case solanago.SplToken__UnwrapSol:
+		account := x.SourceToken
+		if account == "" {
+			ata, _, _ := common.FindAssociatedTokenAddress(p(x.Source), p(solanago.NativeMint))
+			account = ata.ToBase58()
+		}
+		ins = append(ins, tokenprog.CloseAccount(p(account), p(x.Source), p(x.Authority), x.signers()))
+		break
+	case solanago.SplToken__Sweep:
+		source := x.SourceToken
+		if source == "" {
+			ata, _, _ := common.FindAssociatedTokenAddress(p(x.Source), p(x.Mint))
+			source = ata.ToBase58()
+		}
+		if x.Amount > 0 {
+			destination := x.DestinationToken
+			if destination == "" {
+				in := assotokenprog.CreateAssociatedTokenAccount(p(x.funder()), p(x.Destination), p(x.Mint))
+				destination = in.Accounts[1].PubKey.ToBase58()
+				ins = append(ins, in)
+			}
+			ins = append(ins, tokenprog.TransferChecked(p(source), p(destination), p(x.Mint), p(x.Authority), x.signers(), x.Amount, x.Decimals))
+		}
+		// The emptied account is closed and its rent returned to the
+		// owner.
+		ins = append(ins, tokenprog.CloseAccount(p(source), p(x.Source), p(x.Authority), x.signers()))
 		break
 	case solanago.SplToken__Transfer:
-		ins = append(ins, tokenprog.Transfer(p(x.Source), p(x.Destination), p(x.Authority), []common.PublicKey{}, x.Amount))
+		ins = append(ins, tokenprog.Transfer(p(x.Source), p(x.Destination), p(x.Authority), x.signers(), x.Amount))
 		break
 	case solanago.SplToken__TransferChecked:
-		ins = append(ins, tokenprog.TransferChecked(p(x.Source), p(x.Destination), p(x.Mint), p(x.Authority), []common.PublicKey{}, x.Amount, x.Decimals))
+		ins = append(ins, tokenprog.TransferChecked(p(x.Source), p(x.Destination), p(x.Mint), p(x.Authority), x.signers(), x.Amount, x.Decimals))
 		break
 	case solanago.SplToken__TransferNew:
-		ins_create_assoc := assotokenprog.CreateAssociatedTokenAccount(p(x.Authority), p(x.Destination), p(x.Mint))
+		ins_create_assoc := assotokenprog.CreateAssociatedTokenAccount(p(x.funder()), p(x.Destination), p(x.Mint))
 		account := ins_create_assoc.Accounts[1].PubKey.ToBase58()
 		ins = append(ins, ins_create_assoc)
-		ins = append(ins, tokenprog.TransferChecked(p(x.Source), p(account), p(x.Mint), p(x.Authority), []common.PublicKey{}, x.Amount, x.Decimals))
+		ins = append(ins, tokenprog.TransferChecked(p(x.Source), p(account), p(x.Mint), p(x.Authority), x.signers(), x.Amount, x.Decimals))
 		break
 	case solanago.SplToken__TransferWithSystem:
 		source := x.SourceToken
 		destination := x.DestinationToken
 		if x.SourceToken == "" {
-			in := assotokenprog.CreateAssociatedTokenAccount(p(x.Authority), p(x.Source), p(x.Mint))
+			in := assotokenprog.CreateAssociatedTokenAccount(p(x.funder()), p(x.Source), p(x.Mint))
 			source = in.Accounts[1].PubKey.ToBase58()
 			ins = append(ins, in)
 		}
 		if x.DestinationToken == "" {
-			in := assotokenprog.CreateAssociatedTokenAccount(p(x.Authority), p(x.Destination), p(x.Mint))
+			in := assotokenprog.CreateAssociatedTokenAccount(p(x.funder()), p(x.Destination), p(x.Mint))
 			destination = in.Accounts[1].PubKey.ToBase58()
 			ins = append(ins, in)
 		}
-		ins = append(ins, tokenprog.TransferChecked(p(source), p(destination), p(x.Mint), p(x.Authority), []common.PublicKey{}, x.Amount, x.Decimals))
+		ins = append(ins, tokenprog.TransferChecked(p(source), p(destination), p(x.Mint), p(x.Authority), x.signers(), x.Amount, x.Decimals))
 		break
 	}
+	if len(ins) > 0 && len(x.Memo) > 0 {
+		ins = append(ins, memo(x.Memo))
+	}
 	return ins
 }
 
+// signers returns the multisig signers of the authority.
+func (x *SplTokenOperationMetadata) signers() []common.PublicKey {
+	signers := []common.PublicKey{}
+	for _, v := range x.Signers {
+		signers = append(signers, p(v))
+	}
+	return signers
+}
+
+// funder returns the account paying for new token accounts. A multisig
+// authority cannot sign, so its first signer pays instead.
+func (x *SplTokenOperationMetadata) funder() string {
+	if len(x.Signers) > 0 {
+		return x.Signers[0]
+	}
+	return x.Authority
+}
+
+// initializeMultisig is tokenprog.InitializeMultisig without the
+// panics. The signers are only recorded and do not sign.
+func initializeMultisig(multisig common.PublicKey, signers []common.PublicKey, m uint8) solPTypes.Instruction {
+	accounts := []solPTypes.AccountMeta{
+		{PubKey: multisig, IsSigner: false, IsWritable: true},
+		{PubKey: common.SysVarRentPubkey, IsSigner: false, IsWritable: false},
+	}
+	for _, v := range signers {
+		accounts = append(accounts, solPTypes.AccountMeta{PubKey: v, IsSigner: false, IsWritable: false})
+	}
+	return solPTypes.Instruction{
+		ProgramID: common.TokenProgramID,
+		Accounts:  accounts,
+		Data:      []byte{byte(tokenprog.InstructionInitializeMultisig), m},
+	}
+}
+
+// setAuthority builds the SetAuthority instruction, which
+// tokenprog.SetAuthority does not implement.
+func setAuthority(
+	account common.PublicKey,
+	authority common.PublicKey,
+	signers []common.PublicKey,
+	authorityType uint8,
+	newAuthority string,
+) solPTypes.Instruction {
+	data := []byte{byte(tokenprog.InstructionSetAuthority), authorityType}
+	if newAuthority == "" {
+		data = append(data, 0)
+	} else {
+		data = append(data, 1)
+		data = append(data, p(newAuthority).Bytes()...)
+	}
+	accounts := []solPTypes.AccountMeta{
+		{PubKey: account, IsSigner: false, IsWritable: true},
+		{PubKey: authority, IsSigner: len(signers) == 0, IsWritable: false},
+	}
+	for _, v := range signers {
+		accounts = append(accounts, solPTypes.AccountMeta{PubKey: v, IsSigner: true, IsWritable: false})
+	}
+	return solPTypes.Instruction{
+		ProgramID: common.TokenProgramID,
+		Accounts:  accounts,
+		Data:      data,
+	}
+}
+
+// syncNative builds the SyncNative instruction, which updates the
+// amount of a native token account to its lamports.
+func syncNative(account common.PublicKey) solPTypes.Instruction {
+	return solPTypes.Instruction{
+		ProgramID: common.TokenProgramID,
+		Accounts: []solPTypes.AccountMeta{
+			{PubKey: account, IsSigner: false, IsWritable: true},
+		},
+		Data: []byte{solanago.InstructionSyncNative},
+	}
+}
+
+func indexOf(s []string, str string) int {
+	for i, v := range s {
+		if v == str {
+			return i
+		}
+	}
+	return -1
+}
+
 func p(a string) common.PublicKey {
 	return common.PublicKeyFromString(a)
 }
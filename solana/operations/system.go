@@ -2,6 +2,7 @@ package operations
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
@@ -17,11 +18,21 @@ type SystemOperationMetadata struct {
 	Lamports     uint64 `json:"lamports,omitempty"`
 	NewAuthority string `json:"new_authority,omitempty"`
 	Authority    string `json:"authority,omitempty"`
+
+	// Base, Seed and Owner derive the address of the WithSeed
+	// operations. Owner is also the program that owns new accounts.
+	Base  string `json:"base,omitempty"`
+	Seed  string `json:"seed,omitempty"`
+	Owner string `json:"owner,omitempty"`
+
+	// Memo adds a memo instruction after the instructions of the
+	// operation.
+	Memo string `json:"memo,omitempty"`
 }
 
 func (x *SystemOperationMetadata) SetMeta(op *types.Operation) {
 	jsonString, _ := json.Marshal(op.Metadata)
-	if x.Lamports == 0 {
+	if op.Amount != nil && x.Lamports == 0 {
 		x.Lamports = solanago.ValueToBaseAmount(op.Amount.Value)
 	}
 	if x.Source == "" {
@@ -31,18 +42,60 @@ func (x *SystemOperationMetadata) SetMeta(op *types.Operation) {
 		x.Authority = x.Source
 	}
 	json.Unmarshal(jsonString, &x)
+	if x.Base == "" && op.Type == solanago.System__CreateAccountWithSeed {
+		x.Base = x.Source
+	}
+}
+
+// VerifySeed returns an error if a WithSeed operation acting on the
+// derived Source has no Base, or if its Base, Seed and Owner do not
+// derive Source.
+func (x *SystemOperationMetadata) VerifySeed(opType string) error {
+	switch opType {
+	case solanago.System__TransferWithSeed, solanago.System__AssignWithSeed, solanago.System__AllocateWithSeed:
+	default:
+		return nil
+	}
+	if x.Base == "" {
+		return fmt.Errorf("%s requires a base", opType)
+	}
+	if !solanago.IsValidAddress(x.Base) {
+		return fmt.Errorf("invalid base %s", x.Base)
+	}
+	derived := common.CreateWithSeed(p(x.Base), x.Seed, x.owner(common.SystemProgramID)).ToBase58()
+	if derived != x.Source {
+		return fmt.Errorf("base %s and seed %s derive %s, not %s", x.Base, x.Seed, derived, x.Source)
+	}
+	return nil
+}
+
+// VerifyDestination returns an error if the Destination of a
+// CreateAccountWithSeed operation is not the address its Base, Seed and
+// Owner derive.
+func (x *SystemOperationMetadata) VerifyDestination(opType string) error {
+	if opType != solanago.System__CreateAccountWithSeed {
+		return nil
+	}
+	if !solanago.IsValidAddress(x.Base) {
+		return fmt.Errorf("invalid base %s", x.Base)
+	}
+	derived := common.CreateWithSeed(p(x.Base), x.Seed, x.owner(common.SystemProgramID)).ToBase58()
+	if derived != x.Destination {
+		return fmt.Errorf("base %s and seed %s derive %s, not %s", x.Base, x.Seed, derived, x.Destination)
+	}
+	return nil
 }
 func (x *SystemOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
 
 	var ins []solPTypes.Instruction
 	switch opType {
 	case solanago.System__CreateAccount:
-		ins = append(ins, sysprog.CreateAccount(p(x.Source), p(x.Destination), common.TokenProgramID, x.Lamports, x.Space))
+		ins = append(ins, sysprog.CreateAccount(p(x.Source), p(x.Destination), x.owner(common.TokenProgramID), x.Lamports, x.Space))
 		break
 	case solanago.System__Assign:
-		ins = append(ins, sysprog.Assign(p(x.Source), common.TokenProgramID))
+		ins = append(ins, sysprog.Assign(p(x.Source), x.owner(common.TokenProgramID)))
 		break
-	case solanago.System__Transfer:
+	case solanago.System__Transfer, solanago.System__Sweep:
 		ins = append(ins, sysprog.Transfer(p(x.Source), p(x.Destination), x.Lamports))
 		break
 	case solanago.System__CreateNonceAccount:
@@ -70,6 +123,29 @@ func (x *SystemOperationMetadata) ToInstructions(opType string) []solPTypes.Inst
 	case solanago.System__Allocate:
 		ins = append(ins, sysprog.Allocate(p(x.Source), x.Space))
 		break
+	case solanago.System__CreateAccountWithSeed:
+		ins = append(ins, sysprog.CreateAccountWithSeed(p(x.Source), p(x.Destination), p(x.Base), x.owner(common.SystemProgramID), x.Seed, x.Lamports, x.Space))
+		break
+	case solanago.System__TransferWithSeed:
+		ins = append(ins, sysprog.TransferWithSeed(p(x.Source), p(x.Destination), p(x.Base), x.owner(common.SystemProgramID), x.Seed, x.Lamports))
+		break
+	case solanago.System__AssignWithSeed:
+		ins = append(ins, sysprog.AssignWithSeed(p(x.Source), x.owner(common.SystemProgramID), p(x.Base), x.Seed))
+		break
+	case solanago.System__AllocateWithSeed:
+		ins = append(ins, sysprog.AllocateWithSeed(p(x.Source), p(x.Base), x.owner(common.SystemProgramID), x.Seed, x.Space))
+		break
+	}
+	if len(ins) > 0 && len(x.Memo) > 0 {
+		ins = append(ins, memo(x.Memo))
 	}
 	return ins
 }
+
+// owner returns the Owner program or def if it is not set.
+func (x *SystemOperationMetadata) owner(def common.PublicKey) common.PublicKey {
+	if x.Owner == "" {
+		return def
+	}
+	return p(x.Owner)
+}
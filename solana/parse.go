@@ -0,0 +1,365 @@
+package solanago
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/mr-tron/base58"
+	"github.com/portto/solana-go-sdk/common"
+	"github.com/portto/solana-go-sdk/sysprog"
+	"github.com/portto/solana-go-sdk/tokenprog"
+	solPTypes "github.com/portto/solana-go-sdk/types"
+)
+
+// InstructionSyncNative is the token instruction tokenprog has no
+// constant for.
+const InstructionSyncNative = 17
+
+// parseMemo parses a memo instruction the way the node parses it into
+// a jsonParsed transaction.
+func parseMemo(ins solPTypes.Instruction) (solPTypes.ParsedInstruction, error) {
+	return solPTypes.ParsedInstruction{
+		Parsed: &solPTypes.InstructionInfo{
+			Info:            map[string]interface{}{MemoKey: string(ins.Data)},
+			InstructionType: MemoKey,
+		},
+	}, nil
+}
+
+// normalizeMemos rewrites the jsonParsed memo instructions in v, which
+// the node returns with the memo string as "parsed", into the
+// {"type", "info"} form of the other parsed instructions.
+func normalizeMemos(v interface{}) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if memo, ok := x["parsed"].(string); ok {
+			x["parsed"] = map[string]interface{}{
+				"type": MemoKey,
+				"info": map[string]interface{}{MemoKey: memo},
+			}
+		}
+		for _, v := range x {
+			normalizeMemos(v)
+		}
+	case []interface{}:
+		for _, v := range x {
+			normalizeMemos(v)
+		}
+	}
+}
+
+// instructionMemo returns the memo of ins if it is a memo instruction.
+func instructionMemo(ins solPTypes.ParsedInstruction) (string, bool) {
+	if ins.ProgramID != MemoProgramID && ins.ProgramID != MemoV1ProgramID {
+		return "", false
+	}
+	if ins.Parsed != nil {
+		if memo, ok := ins.Parsed.Info[MemoKey].(string); ok {
+			return memo, true
+		}
+	}
+	data, err := base58.Decode(ins.Data)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// GetMemosFromTx returns the memos of tx in instruction order.
+func GetMemosFromTx(tx solPTypes.ParsedTransaction) []string {
+	var memos []string
+	for _, ins := range tx.Message.Instructions {
+		if memo, ok := instructionMemo(ins); ok {
+			memos = append(memos, memo)
+		}
+	}
+	return memos
+}
+
+// parseSystem parses the seed instructions sysprog.ParseSystem cannot
+// decode and the nonce authorization it reads a third account for, and
+// leaves the rest to it.
+func parseSystem(ins solPTypes.Instruction) (solPTypes.ParsedInstruction, error) {
+	if len(ins.Data) < 4 {
+		return solPTypes.ParsedInstruction{}, fmt.Errorf("invalid system instruction")
+	}
+
+	var instructionType string
+	var parsedInfo map[string]interface{}
+	d := &seedDecoder{data: ins.Data[4:]}
+	switch sysprog.Instruction(binary.LittleEndian.Uint32(ins.Data)) {
+	case sysprog.InstructionCreateAccountWithSeed:
+		instructionType = "createAccountWithSeed"
+		if len(ins.Accounts) < 2 {
+			break
+		}
+		parsedInfo = map[string]interface{}{
+			"source":     ins.Accounts[0].PubKey.ToBase58(),
+			"newAccount": ins.Accounts[1].PubKey.ToBase58(),
+			"base":       d.publicKey(),
+			"seed":       d.seed(),
+			"lamports":   d.uint64(),
+			"space":      d.uint64(),
+			"owner":      d.publicKey(),
+		}
+	case sysprog.InstructionAllocateWithSeed:
+		instructionType = "allocateWithSeed"
+		if len(ins.Accounts) < 1 {
+			break
+		}
+		parsedInfo = map[string]interface{}{
+			"account": ins.Accounts[0].PubKey.ToBase58(),
+			"base":    d.publicKey(),
+			"seed":    d.seed(),
+			"space":   d.uint64(),
+			"owner":   d.publicKey(),
+		}
+	case sysprog.InstructionAssignWithSeed:
+		instructionType = "assignWithSeed"
+		if len(ins.Accounts) < 1 {
+			break
+		}
+		parsedInfo = map[string]interface{}{
+			"account": ins.Accounts[0].PubKey.ToBase58(),
+			"base":    d.publicKey(),
+			"seed":    d.seed(),
+			"owner":   d.publicKey(),
+		}
+	case sysprog.InstructionTransferWithSeed:
+		instructionType = "transferWithSeed"
+		if len(ins.Accounts) < 3 {
+			break
+		}
+		parsedInfo = map[string]interface{}{
+			"source":      ins.Accounts[0].PubKey.ToBase58(),
+			"sourceBase":  ins.Accounts[1].PubKey.ToBase58(),
+			"destination": ins.Accounts[2].PubKey.ToBase58(),
+			"lamports":    d.uint64(),
+			"sourceSeed":  d.seed(),
+			"sourceOwner": d.publicKey(),
+		}
+	case sysprog.InstructionAuthorizeNonceAccount:
+		instructionType = "authorizeNonce"
+		if len(ins.Accounts) < 2 {
+			break
+		}
+		parsedInfo = map[string]interface{}{
+			"nonceAccount":   ins.Accounts[0].PubKey.ToBase58(),
+			"nonceAuthority": ins.Accounts[1].PubKey.ToBase58(),
+			"newAuthorized":  d.publicKey(),
+		}
+	default:
+		return sysprog.ParseSystem(ins)
+	}
+	if parsedInfo == nil || d.err != nil {
+		return solPTypes.ParsedInstruction{}, fmt.Errorf("invalid %s instruction", instructionType)
+	}
+
+	return solPTypes.ParsedInstruction{
+		Parsed: &solPTypes.InstructionInfo{
+			Info:            parsedInfo,
+			InstructionType: instructionType,
+		},
+	}, nil
+}
+
+// seedDecoder reads the fields of the system instructions. It
+// records the first read past the end of the data in err.
+type seedDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *seedDecoder) next(n int) []byte {
+	if d.err != nil || n < 0 || len(d.data) < n {
+		d.err = fmt.Errorf("unexpected end of instruction data")
+		return make([]byte, n)
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *seedDecoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.next(8))
+}
+
+func (d *seedDecoder) publicKey() string {
+	return common.PublicKeyFromBytes(d.next(common.PublicKeyLength)).ToBase58()
+}
+
+func (d *seedDecoder) seed() string {
+	n := d.uint64()
+	if n > MaxSeedLength {
+		d.err = fmt.Errorf("seed too long")
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// parseToken parses the token instructions tokenprog.ParseToken gets
+// wrong or does not know and leaves the rest to it.
+func parseToken(ins solPTypes.Instruction) (solPTypes.ParsedInstruction, error) {
+	if len(ins.Data) == 0 {
+		return solPTypes.ParsedInstruction{}, fmt.Errorf("empty token instruction")
+	}
+
+	var instructionType string
+	var parsedInfo map[string]interface{}
+	invalid := func() (solPTypes.ParsedInstruction, error) {
+		return solPTypes.ParsedInstruction{}, fmt.Errorf("invalid %s instruction", instructionType)
+	}
+	switch tokenprog.Instruction(ins.Data[0]) {
+	case tokenprog.InstructionInitializeMultisig:
+		instructionType = "initializeMultisig"
+		if len(ins.Data) < 2 || len(ins.Accounts) < 2 {
+			return invalid()
+		}
+		var signers []string
+		for _, v := range ins.Accounts[2:] {
+			signers = append(signers, v.PubKey.ToBase58())
+		}
+		parsedInfo = map[string]interface{}{
+			"multisig":   ins.Accounts[0].PubKey.ToBase58(),
+			"rentSysvar": ins.Accounts[1].PubKey.ToBase58(),
+			"signers":    signers,
+			"m":          ins.Data[1],
+		}
+	case tokenprog.InstructionSetAuthority:
+		instructionType = "setAuthority"
+		if len(ins.Data) < 3 || len(ins.Accounts) < 2 || int(ins.Data[1]) >= len(AuthorityTypes) {
+			return invalid()
+		}
+		authorityType := AuthorityTypes[ins.Data[1]]
+		var newAuthority interface{}
+		if ins.Data[2] == 1 {
+			if len(ins.Data) < 35 {
+				return invalid()
+			}
+			newAuthority = base58.Encode(ins.Data[3:35])
+		}
+		owned := "account"
+		if ins.Data[1] < 2 {
+			owned = "mint"
+		}
+		parsedInfo = map[string]interface{}{
+			owned:           ins.Accounts[0].PubKey.ToBase58(),
+			"authorityType": authorityType,
+			"newAuthority":  newAuthority,
+		}
+		parsedInfo = parseSigners(parsedInfo, 1, ins.Accounts, "authority", "multisigAuthority")
+	case tokenprog.InstructionBurn:
+		instructionType = "burn"
+		if len(ins.Data) < 9 || len(ins.Accounts) < 3 {
+			return invalid()
+		}
+		parsedInfo = map[string]interface{}{
+			"account": ins.Accounts[0].PubKey.ToBase58(),
+			"mint":    ins.Accounts[1].PubKey.ToBase58(),
+			"amount":  binary.LittleEndian.Uint64(ins.Data[1:9]),
+		}
+		parsedInfo = parseSigners(parsedInfo, 2, ins.Accounts, "authority", "multisigAuthority")
+	case tokenprog.InstructionMintToChecked:
+		instructionType = "mintToChecked"
+		if len(ins.Data) < 10 || len(ins.Accounts) < 3 {
+			return invalid()
+		}
+		parsedInfo = map[string]interface{}{
+			"mint":        ins.Accounts[0].PubKey.ToBase58(),
+			"account":     ins.Accounts[1].PubKey.ToBase58(),
+			"tokenAmount": uiTokenAmount(binary.LittleEndian.Uint64(ins.Data[1:9]), ins.Data[9]),
+		}
+		parsedInfo = parseSigners(parsedInfo, 2, ins.Accounts, "mintAuthority", "multisigMintAuthority")
+	case tokenprog.InstructionBurnChecked:
+		instructionType = "burnChecked"
+		if len(ins.Data) < 10 || len(ins.Accounts) < 3 {
+			return invalid()
+		}
+		parsedInfo = map[string]interface{}{
+			"account":     ins.Accounts[0].PubKey.ToBase58(),
+			"mint":        ins.Accounts[1].PubKey.ToBase58(),
+			"tokenAmount": uiTokenAmount(binary.LittleEndian.Uint64(ins.Data[1:9]), ins.Data[9]),
+		}
+		parsedInfo = parseSigners(parsedInfo, 2, ins.Accounts, "authority", "multisigAuthority")
+	case tokenprog.InstructionTransferChecked:
+		instructionType = "transferChecked"
+		if len(ins.Data) < 10 || len(ins.Accounts) < 4 {
+			return invalid()
+		}
+		parsedInfo = map[string]interface{}{
+			"source":      ins.Accounts[0].PubKey.ToBase58(),
+			"mint":        ins.Accounts[1].PubKey.ToBase58(),
+			"destination": ins.Accounts[2].PubKey.ToBase58(),
+			"tokenAmount": uiTokenAmount(binary.LittleEndian.Uint64(ins.Data[1:9]), ins.Data[9]),
+		}
+		parsedInfo = parseSigners(parsedInfo, 3, ins.Accounts, "authority", "multisigAuthority")
+	case tokenprog.InstructionApproveChecked:
+		instructionType = "approveChecked"
+		if len(ins.Data) < 10 || len(ins.Accounts) < 4 {
+			return invalid()
+		}
+		parsedInfo = map[string]interface{}{
+			"source":      ins.Accounts[0].PubKey.ToBase58(),
+			"mint":        ins.Accounts[1].PubKey.ToBase58(),
+			"delegate":    ins.Accounts[2].PubKey.ToBase58(),
+			"tokenAmount": uiTokenAmount(binary.LittleEndian.Uint64(ins.Data[1:9]), ins.Data[9]),
+		}
+		parsedInfo = parseSigners(parsedInfo, 3, ins.Accounts, "owner", "multisigOwner")
+	case InstructionSyncNative:
+		instructionType = "syncNative"
+		if len(ins.Accounts) < 1 {
+			return invalid()
+		}
+		parsedInfo = map[string]interface{}{
+			"account": ins.Accounts[0].PubKey.ToBase58(),
+		}
+	default:
+		return tokenprog.ParseToken(ins)
+	}
+
+	return solPTypes.ParsedInstruction{
+		Parsed: &solPTypes.InstructionInfo{
+			Info:            parsedInfo,
+			InstructionType: instructionType,
+		},
+	}, nil
+}
+
+// parseSigners records the authority of a token instruction, or the
+// multisig authority and its signers if the instruction has signers
+// after the authority.
+func parseSigners(
+	info map[string]interface{},
+	authorityIndex int,
+	accounts []solPTypes.AccountMeta,
+	authorityField string,
+	multisigField string,
+) map[string]interface{} {
+	if len(accounts) > authorityIndex+1 {
+		var signers []string
+		for _, v := range accounts[authorityIndex+1:] {
+			signers = append(signers, v.PubKey.ToBase58())
+		}
+		info[multisigField] = accounts[authorityIndex].PubKey.ToBase58()
+		info["signers"] = signers
+	} else {
+		info[authorityField] = accounts[authorityIndex].PubKey.ToBase58()
+	}
+	return info
+}
+
+// uiTokenAmount is the tokenAmount field of parsed checked token
+// instructions.
+func uiTokenAmount(amount uint64, decimals uint8) OpMetaTokenAmount {
+	uiAmount, _ := new(big.Float).Quo(
+		new(big.Float).SetUint64(amount),
+		new(big.Float).SetFloat64(math.Pow10(int(decimals))),
+	).Float64()
+	return OpMetaTokenAmount{
+		Amount:   fmt.Sprint(amount),
+		Decimals: uint64(decimals),
+		UiAmount: uiAmount,
+	}
+}
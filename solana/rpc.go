@@ -0,0 +1,243 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solanago
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imerkle/rosetta-solana-go/logger"
+)
+
+// RPCError is an error object returned by the node. Method is the
+// JSON-RPC method that failed.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Method  string          `json:"-"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// IsAlreadyProcessed returns true if err is the error of the node
+// for a transaction it already processed.
+func IsAlreadyProcessed(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return strings.Contains(rpcErr.Message, "already been processed") ||
+		bytes.Contains(rpcErr.Data, []byte("AlreadyProcessed"))
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// rpcAccount is an account object as returned by getAccountInfo,
+// getMultipleAccounts and simulateTransaction.
+type rpcAccount struct {
+	Lamports uint64          `json:"lamports"`
+	Owner    string          `json:"owner"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// toAccountInfo converts an account into an *AccountInfo. Accounts the
+// node cannot parse are returned as [data, encoding] and keep empty
+// parsed data.
+func (a *rpcAccount) toAccountInfo() (*AccountInfo, error) {
+	if a == nil {
+		return nil, nil
+	}
+	info := &AccountInfo{
+		Lamports: a.Lamports,
+		Owner:    a.Owner,
+	}
+	if len(a.Data) > 0 && a.Data[0] == '{' {
+		if err := json.Unmarshal(a.Data, &info.Data); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// call performs a JSON-RPC request and decodes its result into
+// result. The request tries the endpoints in the order of endpointOrder
+// and is retried with exponential backoff while it fails with a
+// retriable error.
+func (ec *Client) call(
+	ctx context.Context,
+	method string,
+	params []interface{},
+	result interface{},
+) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer observeCall(method, time.Now())
+	log := logger.FromContext(ctx)
+	endpoints := ec.endpointOrder()
+	var raw json.RawMessage
+	for attempt := 0; ; attempt++ {
+		e := endpoints[attempt%len(endpoints)]
+		start := time.Now()
+		raw, err = ec.post(ctx, e.url, method, body)
+		observeRequest(ctx, method, e, err)
+		fields := []interface{}{
+			"rpc_method", method,
+			"rpc_endpoint", e.name,
+			"attempt", attempt,
+			"duration", time.Since(start),
+		}
+		if err == nil {
+			log.Debug("rpc call", fields...)
+			break
+		}
+		if !retriable(ctx, err) {
+			log.Debug("rpc call failed", append(fields, "error", err)...)
+			return err
+		}
+		log.Warn("rpc call failed", append(fields, "error", err, "retries", ec.retries-attempt)...)
+		ec.markFailed(e)
+		if attempt >= ec.retries {
+			return err
+		}
+		if ec.backoff(ctx, attempt) != nil {
+			return err
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// transportError is the error of a request that did not get a response
+// from the endpoint.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// statusError is the error of a request the endpoint answered with a
+// non 2xx HTTP status. A 429 status is ErrRateLimited.
+type statusError struct {
+	method string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status code %d", e.method, e.code)
+}
+
+func (e *statusError) Unwrap() error {
+	if e.code == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// post sends the JSON-RPC request body of method to url and returns
+// its result.
+func (ec *Client) post(
+	ctx context.Context,
+	url string,
+	method string,
+	body []byte,
+) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := ec.httpClient.Do(req)
+	if err != nil {
+		return nil, &transportError{err: err}
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, &transportError{err: err}
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, &statusError{method: method, code: res.StatusCode}
+	}
+
+	var rpcRes rpcResponse
+	if err := json.Unmarshal(resBody, &rpcRes); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode %s response", err, method)
+	}
+	if rpcRes.Error != nil {
+		rpcRes.Error.Method = method
+		return nil, rpcRes.Error
+	}
+	return rpcRes.Result, nil
+}
+
+// callParsed is call for methods returning jsonParsed transactions. It
+// normalizes their memo instructions, which the SDK types cannot
+// decode, before decoding the result into result.
+func (ec *Client) callParsed(
+	ctx context.Context,
+	method string,
+	params []interface{},
+	result interface{},
+) error {
+	var raw interface{}
+	if err := ec.call(ctx, method, params, &raw); err != nil {
+		return err
+	}
+	normalizeMemos(raw)
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, result)
+}
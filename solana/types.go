@@ -15,6 +15,8 @@
 package solanago
 
 import (
+	"encoding/json"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
 	bin "github.com/dfuse-io/binary"
 	"github.com/dfuse-io/solana-go"
@@ -64,10 +66,100 @@ const (
 	// genesis block.
 	GenesisBlockIndex = int64(0)
 
+	// MaxTransactionSize is the largest serialized transaction,
+	// signatures included, that fits in a packet.
+	MaxTransactionSize = 1232
+
+	// MaxTransactionAccounts is the largest number of accounts a
+	// transaction can reference.
+	MaxTransactionAccounts = 64
+
+	// TxGroupSeparator joins the encoded transactions of a split intent
+	// into one transaction string.
+	TxGroupSeparator = ","
+
+	// ProcessedCommitment, ConfirmedCommitment and FinalizedCommitment
+	// are the commitments of the node, from lowest to highest.
+	// CommitmentKey overrides the commitment of a request in its
+	// metadata and reports it in responses.
+	CommitmentKey       = "commitment"
+	ProcessedCommitment = "processed"
+	ConfirmedCommitment = "confirmed"
+	FinalizedCommitment = "finalized"
+
+	// TransactionPending, TransactionConfirmed, TransactionFailed and
+	// TransactionExpired are the statuses of a transaction sent by
+	// /construction/submit. A pending transaction is rebroadcast until
+	// it reaches the submit commitment, fails or its blockhash or
+	// nonce expires. TransactionUnknown is the status of a transaction
+	// neither sent by /construction/submit nor known to the node.
+	TransactionPending   = "pending"
+	TransactionConfirmed = "confirmed"
+	TransactionFailed    = "failed"
+	TransactionExpired   = "expired"
+	TransactionUnknown   = "unknown"
+
+	// EncodingKey selects the encoding of the transactions returned by
+	// /construction/payloads. Transactions are encoded in Base58Encoding
+	// or Base64Encoding; HexEncoding is only read.
+	EncodingKey    = "encoding"
+	Base58Encoding = "base58"
+	Base64Encoding = "base64"
+	HexEncoding    = "hex"
+
 	Separator          = "__"
 	WithNonceKey       = "with_nonce"
 	SplSystemAccMapKey = "spl_system_acc_map"
 	SplTokenAccMapKey  = "spl_token_acc_map"
+	PreflightKey       = "preflight"
+	SplitKey           = "split"
+	FeePayerKey        = "fee_payer"
+
+	// SweepKey holds the sweeps of an intent in the options of
+	// /construction/metadata. SlotKey is the slot their amounts must be
+	// resolved at or after.
+	SweepKey = "sweep"
+	SlotKey  = "slot"
+
+	// SeedKey, BaseKey and OwnerKey select the seed-derived address in
+	// the metadata of /construction/derive.
+	SeedKey  = "seed"
+	BaseKey  = "base"
+	OwnerKey = "owner"
+
+	// TransactionHashesKey lists the hashes of every transaction of a
+	// group in the metadata of /construction/hash and submit.
+	TransactionHashesKey = "transaction_hashes"
+
+	// StatusKey is the status of the transaction identified by
+	// /construction/submit, TransactionStatusesKey lists the status of
+	// every transaction of a group.
+	StatusKey              = "status"
+	TransactionStatusesKey = "transaction_statuses"
+
+	// RecipientTokenAccount is the RecipientCheck kind of receivers
+	// that must be an existing token account of the transferred mint.
+	RecipientTokenAccount = "token_account"
+
+	// RecipientWallet is the RecipientCheck kind of receivers that
+	// must be a wallet and not a token account or mint.
+	RecipientWallet = "wallet"
+
+	// MemoProgramID and MemoV1ProgramID are the ids of the SPL memo
+	// programs. Memos are constructed with MemoProgramID.
+	MemoProgramID   = "MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr"
+	MemoV1ProgramID = "Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo"
+
+	// MemoKey is the memo of an operation. MemosKey lists the memos of
+	// a transaction in its metadata.
+	MemoKey  = "memo"
+	MemosKey = "memos"
+
+	// MaxSeedLength is the longest seed of a seed-derived address.
+	MaxSeedLength = 32
+
+	// NativeMint is the mint of wrapped SOL.
+	NativeMint = "So11111111111111111111111111111111111111112"
 
 	MainnetGenesisHash = "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d"
 	TestnetGenesisHash = "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY"
@@ -85,22 +177,71 @@ const (
 	System__WithdrawFromNonce         = "System__WithdrawFromNonce"
 	System__AuthorizeNonce            = "System__AuthorizeNonce"
 	System__Allocate                  = "System__Allocate"
+	System__CreateAccountWithSeed     = "System__CreateAccountWithSeed"
+	System__TransferWithSeed          = "System__TransferWithSeed"
+	System__AssignWithSeed            = "System__AssignWithSeed"
+	System__AllocateWithSeed          = "System__AllocateWithSeed"
+	System__Sweep                     = "System__Sweep"
 	SplToken__Transfer                = "SplToken__Transfer"
 	SplToken__InitializeMint          = "SplToken__InitializeMint"
 	SplToken__InitializeAccount       = "SplToken__InitializeAccount"
+	SplToken__InitializeMultisig      = "SplToken__InitializeMultisig"
 	SplToken__CreateToken             = "SplToken__CreateToken"
 	SplToken__CreateAccount           = "SplToken__CreateAccount"
 	SplToken__Approve                 = "SplToken__Approve"
 	SplToken__Revoke                  = "SplToken__Revoke"
-	SplToken_MintTo                   = "SplToken_MintTo"
-	SplToken_Burn                     = "SplToken_Burn"
-	SplToken_CloseAccount             = "SplToken_CloseAccount"
-	SplToken_FreezeAccount            = "SplToken_FreezeAccount"
+	SplToken__MintTo                  = "SplToken__MintTo"
+	SplToken__MintToChecked           = "SplToken__MintToChecked"
+	SplToken__Burn                    = "SplToken__Burn"
+	SplToken__BurnChecked             = "SplToken__BurnChecked"
+	SplToken__CloseAccount            = "SplToken__CloseAccount"
+	SplToken__FreezeAccount           = "SplToken__FreezeAccount"
+	SplToken__ThawAccount             = "SplToken__ThawAccount"
+	SplToken__SetAuthority            = "SplToken__SetAuthority"
+	SplToken__SyncNative              = "SplToken__SyncNative"
+	SplToken__WrapSol                 = "SplToken__WrapSol"
+	SplToken__UnwrapSol               = "SplToken__UnwrapSol"
+	SplToken__Sweep                   = "SplToken__Sweep"
 	SplToken__TransferChecked         = "SplToken__TransferChecked"
 	SplToken__TransferNew             = "SplToken__TransferNew"
 	SplToken__TransferWithSystem      = "SplToken__TransferWithSystem"
 	SplAssociatedTokenAccount__Create = "SplAssociatedTokenAccount__Create"
 	Unknown                           = "Unknown"
+
+	// Deprecated: the single underscore names were never routed to the
+	// token program. Use the SplToken__ names.
+	SplToken_MintTo        = SplToken__MintTo
+	SplToken_Burn          = SplToken__Burn
+	SplToken_CloseAccount  = SplToken__CloseAccount
+	SplToken_FreezeAccount = SplToken__FreezeAccount
+
+	// BalanceChange is the type of the operations a simulation
+	// predicts. It is only returned by /call.
+	BalanceChange = "BalanceChange"
+)
+
+// AuthorityTypes are the SplToken__SetAuthority authority types,
+// indexed by their value in the instruction.
+var AuthorityTypes = []string{
+	"mintTokens",
+	"freezeAccount",
+	"accountOwner",
+	"closeAccount",
+}
+
+//call methods
+
+const (
+	// CallSimulate simulates a transaction returned by
+	// /construction/payloads or /construction/combine.
+	CallSimulate = "construction_simulate"
+
+	// CallStatus reports the status of a transaction sent by
+	// /construction/submit.
+	CallStatus = "construction_status"
+
+	// CallBlockCacheStats reports the statistics of the block cache.
+	CallBlockCacheStats = "block_cache_stats"
 )
 
 var (
@@ -141,17 +282,31 @@ var (
 		System__WithdrawFromNonce,
 		System__AuthorizeNonce,
 		System__Allocate,
+		System__CreateAccountWithSeed,
+		System__TransferWithSeed,
+		System__AssignWithSeed,
+		System__AllocateWithSeed,
+		System__Sweep,
 		SplToken__Transfer,
 		SplToken__InitializeMint,
 		SplToken__InitializeAccount,
+		SplToken__InitializeMultisig,
 		SplToken__CreateToken,
 		SplToken__CreateAccount,
 		SplToken__Approve,
 		SplToken__Revoke,
-		SplToken_MintTo,
-		SplToken_Burn,
-		SplToken_CloseAccount,
-		SplToken_FreezeAccount,
+		SplToken__MintTo,
+		SplToken__MintToChecked,
+		SplToken__Burn,
+		SplToken__BurnChecked,
+		SplToken__CloseAccount,
+		SplToken__FreezeAccount,
+		SplToken__ThawAccount,
+		SplToken__SetAuthority,
+		SplToken__SyncNative,
+		SplToken__WrapSol,
+		SplToken__UnwrapSol,
+		SplToken__Sweep,
 		SplToken__TransferChecked,
 		SplToken__TransferNew,
 		SplToken__TransferWithSystem,
@@ -173,6 +328,9 @@ var (
 
 	// CallMethods are all supported call methods.
 	CallMethods = []string{
+		CallSimulate,
+		CallStatus,
+		CallBlockCacheStats,
 		"deregisterNode", "validatorExit", "getAccountInfo", "getBalance", "getBlockTime", "getClusterNodes", "getConfirmedBlock", "getConfirmedBlocks", "getConfirmedBlocksWithLimit", "getConfirmedSignaturesForAddress", "getConfirmedSignaturesForAddress2", "getConfirmedTransaction", "getEpochInfo", "getEpochSchedule", "getFeeCalculatorForBlockhash", "getFeeRateGovernor", "getFees", "getFirstAvailableBlock", "getGenesisHash", "getHealth", "getIdentity", "getInflationGovernor", "getInflationRate", "getLargestAccounts", "getLeaderSchedule", "getMinimumBalanceForRentExemption", "getMultipleAccounts", "getProgramAccounts", "getRecentBlockhash", "getSnapshotSlot", "getSignatureStatuses", "getSlot", "getSlotLeader", "getStorageTurn", "getStorageTurnRate", "getSlotsPerSegment", "getStoragePubkeysForSlot", "getSupply", "getTokenAccountBalance", "getTokenAccountsByDelegate", "getTokenAccountsByOwner", "getTokenSupply", "getTotalSupply", "getTransactionCount", "getVersion", "getVoteAccounts", "minimumLedgerSlot", "registerNode", "requestAirdrop", "sendTransaction", "simulateTransaction", "signVote",
 	}
 )
@@ -201,6 +359,8 @@ type ParsedInstructionMeta struct {
 	Amount       uint64            `json:"amount,omitempty"`
 	Lamports     uint64            `json:"lamports,omitempty"`
 	Space        uint64            `json:"space,omitempty"`
+	Multisig     string            `json:"multisig,omitempty"`
+	NonceAccount string            `json:"nonceAccount,omitempty"`
 }
 type OpMetaTokenAmount struct {
 	Amount   string  `json:"amount,omitempty"`
@@ -222,8 +382,116 @@ type WithNonce struct {
 	Authority string `json:"authority,omitempty"`
 }
 
+// Pooled returns true if the nonce account is to be allocated from the
+// nonce pool of the authority.
+func (w WithNonce) Pooled() bool {
+	return len(w.Account) == 0 && len(w.Authority) > 0
+}
+
+// SignatureStatus is the status of a transaction as returned by
+// getSignatureStatuses.
+type SignatureStatus struct {
+	Slot               uint64      `json:"slot"`
+	Confirmations      *uint64     `json:"confirmations"`
+	Err                interface{} `json:"err"`
+	ConfirmationStatus string      `json:"confirmationStatus"`
+}
+
+// TransactionOutcome is the slot, error and fee of a confirmed
+// transaction.
+type TransactionOutcome struct {
+	Slot uint64      `json:"slot"`
+	Err  interface{} `json:"err"`
+	Fee  uint64      `json:"fee"`
+}
+
 type SplAccounts struct {
 	Source      string `json:"source"`
 	Destination string `json:"destination"`
 	Mint        string `json:"mint"`
 }
+
+// Sweep is an account drained by a System__Sweep or SplToken__Sweep
+// operation. Mint is empty for native SOL. /construction/metadata
+// resolves Amount, Decimals and the Slot it read the balance at.
+type Sweep struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Mint        string `json:"mint,omitempty"`
+	Amount      uint64 `json:"amount"`
+	Decimals    uint8  `json:"decimals,omitempty"`
+	Slot        uint64 `json:"slot,omitempty"`
+}
+
+// Preflight is the set of online checks /construction/metadata runs
+// before handing out a blockhash. It is populated by
+// /construction/preprocess from the requested operations.
+type Preflight struct {
+	FeePayer   string           `json:"fee_payer"`
+	Signers    []string         `json:"signers"`
+	Balances   []BalanceCheck   `json:"balances,omitempty"`
+	Recipients []RecipientCheck `json:"recipients,omitempty"`
+}
+
+// BalanceCheck is an amount an account must be able to spend.
+// Mint is empty for native SOL.
+type BalanceCheck struct {
+	Account string `json:"account"`
+	Mint    string `json:"mint,omitempty"`
+	Amount  uint64 `json:"amount"`
+}
+
+// RecipientCheck is the account type a receiver must have.
+type RecipientCheck struct {
+	Account string `json:"account"`
+	Mint    string `json:"mint,omitempty"`
+	Kind    string `json:"kind"`
+}
+
+// AccountInfo is an account as returned by getAccountInfo
+// with jsonParsed encoding.
+type AccountInfo struct {
+	Lamports uint64
+	Owner    string
+	Data     ParsedAccountData
+}
+
+type ParsedAccountData struct {
+	Program string            `json:"program"`
+	Parsed  ParsedAccountType `json:"parsed"`
+}
+
+type ParsedAccountType struct {
+	Type string            `json:"type"`
+	Info ParsedAccountInfo `json:"info"`
+}
+
+type ParsedAccountInfo struct {
+	Mint          string                   `json:"mint,omitempty"`
+	Owner         string                   `json:"owner,omitempty"`
+	State         string                   `json:"state,omitempty"`
+	IsNative      bool                     `json:"isNative,omitempty"`
+	TokenAmount   OpMetaTokenAmount        `json:"tokenAmount,omitempty"`
+	Authority     string                   `json:"authority,omitempty"`
+	Blockhash     string                   `json:"blockhash,omitempty"`
+	FeeCalculator ParsedNonceFeeCalculator `json:"feeCalculator,omitempty"`
+}
+
+// ParsedNonceFeeCalculator is the fee calculator stored in a nonce
+// account. The node encodes lamportsPerSignature as a string.
+type ParsedNonceFeeCalculator struct {
+	LamportsPerSignature json.Number `json:"lamportsPerSignature"`
+}
+
+// Simulation is the result of simulating a transaction at Slot. Pre and
+// Post hold the state of the account at the same index of Addresses and
+// are nil for accounts that do not exist.
+type Simulation struct {
+	Err           interface{}
+	Logs          []string
+	UnitsConsumed uint64
+	Slot          uint64
+	Addresses     []string
+	Pre           []*AccountInfo
+	Post          []*AccountInfo
+}
@@ -1,6 +1,8 @@
 package solanago
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -14,8 +16,6 @@ import (
 	"github.com/portto/solana-go-sdk/assotokenprog"
 	ss "github.com/portto/solana-go-sdk/client"
 	common "github.com/portto/solana-go-sdk/common"
-	"github.com/portto/solana-go-sdk/sysprog"
-	"github.com/portto/solana-go-sdk/tokenprog"
 	solPTypes "github.com/portto/solana-go-sdk/types"
 
 	"github.com/iancoleman/strcase"
@@ -24,7 +24,7 @@ import (
 func IsBalanceChanging(opType string) bool {
 	a := false
 	switch opType {
-	case System__CreateAccount, System__WithdrawFromNonce, System__Transfer, SplToken__Transfer, SplToken__TransferChecked, "Stake__Split", "Stake__Withdraw", "Vote__Withdraw", SplToken__TransferNew, SplToken__TransferWithSystem:
+	case System__CreateAccount, System__CreateAccountWithSeed, System__WithdrawFromNonce, System__Transfer, System__TransferWithSeed, SplToken__Transfer, SplToken__TransferChecked, "Stake__Split", "Stake__Withdraw", "Vote__Withdraw", SplToken__TransferNew, SplToken__TransferWithSystem:
 		a = true
 	}
 	return a
@@ -54,7 +54,26 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 	//	hash := tx.Transaction.Signatures[0].String()
 	opIndex := int64(0)
 	var operations []*types.Operation
-	for _, ins := range tx.Message.Instructions {
+	opTypes, folded := wrappedSolOperations(tx.Message.Instructions)
+	prevFirst := 0
+	for i, ins := range tx.Message.Instructions {
+		if folded[i] {
+			continue
+		}
+		if memo, ok := instructionMemo(ins); ok {
+			// A memo describes the operations of the instruction
+			// before it.
+			for _, op := range operations[prevFirst:] {
+				if op.Metadata == nil {
+					op.Metadata = map[string]interface{}{}
+				}
+				op.Metadata[MemoKey] = memo
+			}
+			prevFirst = len(operations)
+			continue
+		}
+		first := len(operations)
+		prevFirst = first
 		oi := types.OperationIdentifier{
 			Index: opIndex,
 		}
@@ -77,13 +96,17 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 			jsonString, _ := json.Marshal(ins.Parsed.Info)
 
 			parsedInstructionMeta := ParsedInstructionMeta{}
-			var parsedInstructionMetaInterface interface{}
 			json.Unmarshal(jsonString, &parsedInstructionMeta)
-			json.Unmarshal(jsonString, &parsedInstructionMetaInterface)
 
+			// Numbers stay json.Number so that amounts above 2^53 keep
+			// their precision.
 			var inInterface map[string]interface{}
-			inrec, _ := json.Marshal(parsedInstructionMetaInterface)
-			json.Unmarshal(inrec, &inInterface)
+			d := json.NewDecoder(bytes.NewReader(jsonString))
+			d.UseNumber()
+			d.Decode(&inInterface)
+			if inInterface == nil {
+				inInterface = map[string]interface{}{}
+			}
 
 			opType := getOperationTypeWithProgram(ins.Program, ins.Parsed.InstructionType)
 			if !Contains(OperationTypes, opType) {
@@ -93,7 +116,11 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 			}
 			if IsBalanceChanging(opType) {
 				if parsedInstructionMeta.Decimals == 0 {
-					parsedInstructionMeta.Decimals = Decimals
+					if parsedInstructionMeta.TokenAmount.Amount != "" {
+						parsedInstructionMeta.Decimals = uint8(parsedInstructionMeta.TokenAmount.Decimals)
+					} else {
+						parsedInstructionMeta.Decimals = Decimals
+					}
 				}
 				if parsedInstructionMeta.Amount == 0 {
 					if parsedInstructionMeta.Lamports == 0 {
@@ -123,6 +150,9 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 				if source == "" {
 					source = parsedInstructionMeta.Owner
 				}
+				if source == "" {
+					source = parsedInstructionMeta.NonceAccount
+				}
 				sender := types.AccountIdentifier{
 					Address:  source,
 					Metadata: map[string]interface{}{},
@@ -173,7 +203,12 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 				})
 			} else {
 				var account types.AccountIdentifier
-				if parsedInstructionMeta.Source != "" {
+				if ins.Program == "system" && parsedInstructionMeta.Account != "" {
+					// The owner of a system instruction is a program.
+					account = types.AccountIdentifier{
+						Address: parsedInstructionMeta.Account,
+					}
+				} else if parsedInstructionMeta.Source != "" {
 					account = types.AccountIdentifier{
 						Address: parsedInstructionMeta.Source,
 					}
@@ -187,6 +222,18 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 							account = types.AccountIdentifier{
 								Address: parsedInstructionMeta.Account,
 							}
+						} else if parsedInstructionMeta.Multisig != "" {
+							account = types.AccountIdentifier{
+								Address: parsedInstructionMeta.Multisig,
+							}
+						} else if parsedInstructionMeta.Mint != "" {
+							account = types.AccountIdentifier{
+								Address: parsedInstructionMeta.Mint,
+							}
+						} else if parsedInstructionMeta.NonceAccount != "" {
+							account = types.AccountIdentifier{
+								Address: parsedInstructionMeta.NonceAccount,
+							}
 						}
 					}
 				}
@@ -200,10 +247,148 @@ func GetRosOperationsFromTx(tx solPTypes.ParsedTransaction, status string) []*ty
 				})
 			}
 		}
+		if opType, ok := opTypes[i]; ok {
+			for _, op := range operations[first:] {
+				op.Type = opType
+			}
+		}
+	}
+	return operations
+}
+
+// wrappedSolOperations finds the instructions that wrap or unwrap SOL.
+// It returns the operation type of the system transfer of a wrap and of
+// the token account close of an unwrap, and the sync native
+// instructions that are folded into the wrap.
+func wrappedSolOperations(instructions []solPTypes.ParsedInstruction) (map[int]string, map[int]bool) {
+	opTypes := make(map[int]string)
+	folded := make(map[int]bool)
+	for i, ins := range instructions {
+		if ins.Parsed == nil {
+			continue
+		}
+		meta := parsedInstructionMeta(ins)
+		switch getOperationTypeWithProgram(ins.Program, ins.Parsed.InstructionType) {
+		case System__Transfer:
+			if i+1 >= len(instructions) || instructions[i+1].Parsed == nil {
+				continue
+			}
+			next := instructions[i+1]
+			if getOperationTypeWithProgram(next.Program, next.Parsed.InstructionType) != SplToken__SyncNative {
+				continue
+			}
+			if parsedInstructionMeta(next).Account != meta.Destination {
+				continue
+			}
+			opTypes[i] = SplToken__WrapSol
+			folded[i+1] = true
+		case SplToken__CloseAccount:
+			owner := meta.Owner
+			if owner == "" || meta.Destination != owner {
+				continue
+			}
+			ata, _, err := common.FindAssociatedTokenAddress(common.PublicKeyFromString(owner), common.PublicKeyFromString(NativeMint))
+			if err == nil && ata.ToBase58() == meta.Account {
+				opTypes[i] = SplToken__UnwrapSol
+			}
+		}
+	}
+	return opTypes, folded
+}
+
+func parsedInstructionMeta(ins solPTypes.ParsedInstruction) ParsedInstructionMeta {
+	var meta ParsedInstructionMeta
+	jsonString, _ := json.Marshal(ins.Parsed.Info)
+	json.Unmarshal(jsonString, &meta)
+	return meta
+}
+
+// GetRosOperationsFromSimulation returns one BalanceChange operation per
+// SOL or token balance the simulation changed. Token balance changes are
+// reported on the token account with its owner in the metadata.
+func GetRosOperationsFromSimulation(sim *Simulation) []*types.Operation {
+	status := SuccessStatus
+	if sim.Err != nil {
+		status = FailureStatus
+	}
+	var operations []*types.Operation
+	addOperation := func(address string, diff *big.Int, currency *types.Currency, meta map[string]interface{}) {
+		if diff.Sign() == 0 {
+			return
+		}
+		operations = append(operations, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(len(operations)),
+			},
+			Type:   BalanceChange,
+			Status: &status,
+			Account: &types.AccountIdentifier{
+				Address:  address,
+				Metadata: meta,
+			},
+			Amount: &types.Amount{
+				Value:    diff.String(),
+				Currency: currency,
+			},
+		})
+	}
+
+	for i, address := range sim.Addresses {
+		var pre, post *AccountInfo
+		if i < len(sim.Pre) {
+			pre = sim.Pre[i]
+		}
+		if i < len(sim.Post) {
+			post = sim.Post[i]
+		}
+
+		lamports := new(big.Int).Sub(
+			new(big.Int).SetUint64(accountLamports(post)),
+			new(big.Int).SetUint64(accountLamports(pre)),
+		)
+		addOperation(address, lamports, Currency, nil)
+
+		preToken, postToken := tokenAccountInfo(pre), tokenAccountInfo(post)
+		token := postToken
+		if token == nil {
+			token = preToken
+		}
+		if token == nil {
+			continue
+		}
+		amount := new(big.Int).Sub(tokenAmount(postToken), tokenAmount(preToken))
+		addOperation(address, amount, &types.Currency{
+			Symbol:   token.Mint,
+			Decimals: int32(token.TokenAmount.Decimals),
+		}, map[string]interface{}{
+			"owner": token.Owner,
+		})
 	}
 	return operations
 }
 
+func accountLamports(acc *AccountInfo) uint64 {
+	if acc == nil {
+		return 0
+	}
+	return acc.Lamports
+}
+
+func tokenAccountInfo(acc *AccountInfo) *ParsedAccountInfo {
+	if acc == nil || acc.Owner != common.TokenProgramID.ToBase58() || acc.Data.Parsed.Type != "account" {
+		return nil
+	}
+	return &acc.Data.Parsed.Info
+}
+
+func tokenAmount(info *ParsedAccountInfo) *big.Int {
+	amount := new(big.Int)
+	if info != nil {
+		amount.SetString(info.TokenAmount.Amount, 10)
+	}
+	return amount
+}
+
 func ToRosTxs(txs []ss.ParsedTransactionWithMeta) []*RosettaTypes.Transaction {
 	var rtxs []*RosettaTypes.Transaction
 	for _, tx := range txs {
@@ -213,12 +398,16 @@ func ToRosTxs(txs []ss.ParsedTransactionWithMeta) []*RosettaTypes.Transaction {
 	return rtxs
 }
 func ToRosTx(tx solPTypes.ParsedTransaction) RosettaTypes.Transaction {
+	metadata := map[string]interface{}{}
+	if memos := GetMemosFromTx(tx); len(memos) > 0 {
+		metadata[MemosKey] = memos
+	}
 	return RosettaTypes.Transaction{
 		TransactionIdentifier: &RosettaTypes.TransactionIdentifier{
 			Hash: tx.Signatures[0],
 		},
 		Operations: GetRosOperationsFromTx(tx, SuccessStatus),
-		Metadata:   map[string]interface{}{},
+		Metadata:   metadata,
 	}
 }
 
@@ -255,22 +444,200 @@ func GetWithNonce(m map[string]interface{}) (WithNonce, bool) {
 	}
 	return withNonce, hasNonce
 }
+func GetPreflight(m map[string]interface{}) (Preflight, bool) {
+	var preflight Preflight
+	hasPreflight := false
+	if w, ok := m[PreflightKey]; ok {
+		j, _ := json.Marshal(w)
+		json.Unmarshal(j, &preflight)
+		if len(preflight.FeePayer) > 0 {
+			hasPreflight = true
+		}
+	}
+	return preflight, hasPreflight
+}
+func GetFeePayer(m map[string]interface{}) (string, bool) {
+	feePayer, _ := m[FeePayerKey].(string)
+	return feePayer, len(feePayer) > 0
+}
+
+// IsValidAddress returns true if address is a base58 encoded public key.
+func IsValidAddress(address string) bool {
+	b, err := base58.Decode(address)
+	return err == nil && len(b) == common.PublicKeyLength
+}
+
+func GetSplit(m map[string]interface{}) bool {
+	split, _ := m[SplitKey].(bool)
+	return split
+}
+
+// SplitTxGroup returns the encoded transactions of a transaction string
+// made by joining them with TxGroupSeparator.
+func SplitTxGroup(t string) []string {
+	return strings.Split(t, TxGroupSeparator)
+}
+
+// GetTxsFromStr decodes every transaction of a transaction group.
+func GetTxsFromStr(t string) ([]solPTypes.Transaction, error) {
+	txs, _, err := DecodeTxGroup(t)
+	return txs, err
+}
+
+// DecodeTxGroup decodes every transaction of a transaction group and
+// returns the encoding of the group, the encoding of its first
+// transaction.
+func DecodeTxGroup(t string) ([]solPTypes.Transaction, string, error) {
+	var txs []solPTypes.Transaction
+	var encoding string
+	for i, v := range SplitTxGroup(t) {
+		tx, e, err := DecodeTx(v)
+		if err != nil {
+			return nil, "", err
+		}
+		if i == 0 {
+			encoding = e
+		}
+		txs = append(txs, tx)
+	}
+	return txs, encoding, nil
+}
+
 func GetTxFromStr(t string) (solPTypes.Transaction, error) {
-	signedTx, err := base58.Decode(t)
-	if err != nil {
-		signedTx, err = hex.DecodeString(t)
+	tx, _, err := DecodeTx(t)
+	return tx, err
+}
+
+// DecodeTx decodes a base58, hex or base64 encoded transaction and
+// returns it with its encoding. An encoding is only detected if the
+// bytes it decodes to are exactly a serialized transaction.
+func DecodeTx(t string) (solPTypes.Transaction, string, error) {
+	err := fmt.Errorf("empty transaction")
+	for _, encoding := range []string{Base58Encoding, HexEncoding, Base64Encoding} {
+		var b []byte
+		b, err = decodeBytes(t, encoding)
 		if err != nil {
-			return solPTypes.Transaction{}, err
+			continue
 		}
+		var tx solPTypes.Transaction
+		tx, err = deserializeTx(b)
+		if err == nil {
+			return tx, encoding, nil
+		}
+	}
+	return solPTypes.Transaction{}, "", err
+}
+
+// EncodeTx encodes a serialized transaction in encoding.
+func EncodeTx(b []byte, encoding string) string {
+	switch encoding {
+	case Base64Encoding:
+		return base64.StdEncoding.EncodeToString(b)
+	case HexEncoding:
+		return hex.EncodeToString(b)
+	default:
+		return base58.Encode(b)
+	}
+}
+
+// IsValidEncoding returns true if transactions can be encoded in
+// encoding.
+func IsValidEncoding(encoding string) bool {
+	return encoding == Base58Encoding || encoding == Base64Encoding
+}
+
+// IsValidCommitment returns true if commitment is a commitment of the
+// node.
+func IsValidCommitment(commitment string) bool {
+	return CommitmentRank(commitment) >= 0
+}
+
+// CommitmentRank orders commitments from ProcessedCommitment to
+// FinalizedCommitment. It is -1 for unknown commitments.
+func CommitmentRank(commitment string) int {
+	switch commitment {
+	case ProcessedCommitment:
+		return 0
+	case ConfirmedCommitment:
+		return 1
+	case FinalizedCommitment:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// GetCommitment returns the commitment set in m.
+func GetCommitment(m map[string]interface{}) (string, bool) {
+	commitment, _ := m[CommitmentKey].(string)
+	return commitment, len(commitment) > 0
+}
+
+// GetEncoding returns the encoding set in m.
+func GetEncoding(m map[string]interface{}) (string, bool) {
+	encoding, _ := m[EncodingKey].(string)
+	return encoding, len(encoding) > 0
+}
+
+func decodeBytes(t string, encoding string) ([]byte, error) {
+	if len(t) == 0 {
+		return nil, fmt.Errorf("empty transaction")
+	}
+	switch encoding {
+	case Base64Encoding:
+		return base64.StdEncoding.DecodeString(t)
+	case HexEncoding:
+		return hex.DecodeString(t)
+	default:
+		return base58.Decode(t)
 	}
+}
 
-	tx, err := solPTypes.TransactionDeserialize(signedTx)
+// deserializeTx deserializes b and checks that it serializes back to b.
+// TransactionDeserialize panics on some malformed input.
+func deserializeTx(b []byte) (tx solPTypes.Transaction, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			tx, err = solPTypes.Transaction{}, fmt.Errorf("invalid transaction: %v", r)
+		}
+	}()
+	tx, err = solPTypes.TransactionDeserialize(b)
 	if err != nil {
 		return solPTypes.Transaction{}, err
 	}
-
+	raw, err := tx.Serialize()
+	if err != nil {
+		return solPTypes.Transaction{}, err
+	}
+	if !bytes.Equal(raw, b) {
+		return solPTypes.Transaction{}, fmt.Errorf("invalid transaction: unexpected trailing bytes")
+	}
 	return tx, nil
 }
+
+// IsSigned returns true if every signature slot of tx holds a signature.
+// Transactions returned by /construction/payloads have zeroed slots.
+func IsSigned(tx solPTypes.Transaction) bool {
+	if len(tx.Signatures) == 0 {
+		return false
+	}
+	for _, sig := range tx.Signatures {
+		if !HasSignature(sig) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasSignature returns true if the signature slot sig is not zeroed.
+func HasSignature(sig solPTypes.Signature) bool {
+	for _, b := range sig {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
 func ToParsedTransaction(tx solPTypes.Transaction) (solPTypes.ParsedTransaction, error) {
 	ins := tx.Message.DecompileInstructions()
 	var parsedIns []solPTypes.ParsedInstruction
@@ -308,14 +675,17 @@ func ParseInstruction(ins solPTypes.Instruction) (solPTypes.ParsedInstruction, e
 
 	switch ins.ProgramID {
 	case common.SystemProgramID:
-		parsedInstruction, err = sysprog.ParseSystem(ins)
+		parsedInstruction, err = parseSystem(ins)
 		break
 	case common.TokenProgramID:
-		parsedInstruction, err = tokenprog.ParseToken(ins)
+		parsedInstruction, err = parseToken(ins)
 		break
 	case common.SPLAssociatedTokenAccountProgramID:
 		parsedInstruction, err = assotokenprog.ParseAssocToken(ins)
 		break
+	case common.PublicKeyFromString(MemoProgramID), common.PublicKeyFromString(MemoV1ProgramID):
+		parsedInstruction, err = parseMemo(ins)
+		break
 	default:
 		//return parsedInstruction, fmt.Errorf("Cannot parse instruction")
 	}
@@ -330,6 +700,9 @@ func ParseInstruction(ins solPTypes.Instruction) (solPTypes.ParsedInstruction, e
 	parsedInstruction.Data = base58.Encode(ins.Data[:])
 	parsedInstruction.ProgramID = ins.ProgramID.ToBase58()
 	parsedInstruction.Program = common.GetProgramName(ins.ProgramID)
+	if _, ok := instructionMemo(parsedInstruction); ok {
+		parsedInstruction.Program = "spl-memo"
+	}
 	return parsedInstruction, nil
 }
 func ValueToBaseAmount(valueStr string) uint64 {
@@ -1,8 +1,14 @@
 package solanago
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 
+	solPTypes "github.com/portto/solana-go-sdk/types"
 	"github.com/test-go/testify/assert"
 )
 
@@ -17,3 +23,140 @@ func TestTxParse(t *testing.T) {
 	_, err = ToParsedTransaction(tx)
 	assert.NoError(t, err)
 }
+
+func TestDecodeTx(t *testing.T) {
+	encoded := "64dq82ETBCJ9zzS6cUGqKc8L8bZ2ZTao3wR2nARKFqBywDccMta29VgGVNK2oza3nhoqidoUZczgyfNgmoTuYrdro3UXdwwVh5TVMx2CUzFUGUmmRmsaqJ1QnFxHQCUzhbroCddPPfvjw9edG3v1aetyNRknQtxgjXEjzkgn9EGtY3mo5XoRiw38qmwqACNkdsKqfNCcG5SC9mujtCoLaFXcmnVeAcdLMgBxXsTjv1JtiLpaWsB5g7TcEo2hLHL8sLV7ZiVsn66xA1ZBdAcFsLu572CHKQ8JJkgkX"
+	tx, encoding, err := DecodeTx(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, Base58Encoding, encoding)
+	raw, err := tx.Serialize()
+	assert.NoError(t, err)
+
+	for _, enc := range []string{Base58Encoding, Base64Encoding, HexEncoding} {
+		decoded, encoding, err := DecodeTx(EncodeTx(raw, enc))
+		assert.NoError(t, err)
+		assert.Equal(t, enc, encoding)
+		assert.Equal(t, tx, decoded)
+	}
+
+	txs, encoding, err := DecodeTxGroup(EncodeTx(raw, Base64Encoding) + TxGroupSeparator + EncodeTx(raw, Base58Encoding))
+	assert.NoError(t, err)
+	assert.Equal(t, Base64Encoding, encoding)
+	assert.Len(t, txs, 2)
+
+	for _, invalid := range []string{
+		"",
+		"not a transaction",
+		base64.StdEncoding.EncodeToString(raw[:len(raw)-1]),
+		base64.StdEncoding.EncodeToString(append(raw, 0)),
+		hex.EncodeToString([]byte{1, 0, 0, 0}),
+		base64.StdEncoding.EncodeToString(append([]byte{1}, make([]byte, 67)...)),
+	} {
+		_, _, err := DecodeTx(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err   error
+		class error
+	}{
+		{&RPCError{Code: -32002, Message: "Transaction simulation failed: Blockhash not found", Data: json.RawMessage(`{"err":"BlockhashNotFound"}`)}, ErrBlockhashNotFound},
+		{&RPCError{Code: -32002, Message: "Transaction simulation failed", Data: json.RawMessage(`{"err":{"InstructionError":[0,{"Custom":1}]}}`)}, ErrTransactionFailed},
+		{&RPCError{Code: -32602, Message: "Invalid params: invalid type"}, ErrInvalidParams},
+		{&RPCError{Code: -32009, Message: "Slot 5 was skipped, or missing in long-term storage"}, ErrSlotSkipped},
+		{&RPCError{Code: -32016, Message: "Minimum context slot has not been reached"}, ErrNodeBehind},
+		{&RPCError{Code: -32601, Message: "Method not found"}, ErrMethodNotFound},
+		// Errors passed on by the SDK only keep their message.
+		{errors.New("Transaction simulation failed: Attempt to debit an account but found no record of a prior credit."), ErrAccountNotFound},
+		{errors.New("Transaction simulation failed: Insufficient funds for fee"), ErrInsufficientFundsForFee},
+		{errors.New("Node is unhealthy"), ErrNodeBehind},
+		{errors.New("get status code: 429"), ErrRateLimited},
+		{fmt.Errorf("%w: getSlot: unexpected status code 429", ErrRateLimited), ErrRateLimited},
+		{errors.New("connection refused"), nil},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.class, ClassifyError(tt.err), fmt.Sprint(tt.err))
+	}
+}
+
+func TestGetRosOperationsFromSimulation(t *testing.T) {
+	tokenProgram := "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+	tokenAcc := func(amount string) *AccountInfo {
+		return &AccountInfo{
+			Lamports: 2039280,
+			Owner:    tokenProgram,
+			Data: ParsedAccountData{
+				Program: "spl-token",
+				Parsed: ParsedAccountType{
+					Type: "account",
+					Info: ParsedAccountInfo{
+						Mint:        "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr",
+						Owner:       "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+						TokenAmount: OpMetaTokenAmount{Amount: amount, Decimals: 2},
+					},
+				},
+			},
+		}
+	}
+	sim := &Simulation{
+		Addresses: []string{"fee", "new", "token"},
+		Pre:       []*AccountInfo{{Lamports: 100}, nil, tokenAcc("10")},
+		Post:      []*AccountInfo{{Lamports: 40}, {Lamports: 50}, tokenAcc("7")},
+	}
+	ops := GetRosOperationsFromSimulation(sim)
+	assert.Len(t, ops, 3)
+	assert.Equal(t, "-60", ops[0].Amount.Value)
+	assert.Equal(t, Symbol, ops[0].Amount.Currency.Symbol)
+	assert.Equal(t, "50", ops[1].Amount.Value)
+	assert.Equal(t, "new", ops[1].Account.Address)
+	assert.Equal(t, "-3", ops[2].Amount.Value)
+	assert.Equal(t, "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", ops[2].Amount.Currency.Symbol)
+	assert.Equal(t, int64(2), ops[2].OperationIdentifier.Index)
+	assert.Equal(t, SuccessStatus, *ops[2].Status)
+}
+
+func TestMemosFromParsedTx(t *testing.T) {
+	var raw interface{}
+	err := json.Unmarshal([]byte(`{
+		"signatures": ["5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW"],
+		"message": {
+			"accountKeys": [],
+			"recentBlockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+			"instructions": [
+				{
+					"program": "system",
+					"programId": "11111111111111111111111111111111",
+					"parsed": {
+						"type": "transfer",
+						"info": {
+							"source": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+							"destination": "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v",
+							"lamports": 1000
+						}
+					}
+				},
+				{
+					"program": "spl-memo",
+					"programId": "MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr",
+					"parsed": "deposit 42"
+				}
+			]
+		}
+	}`), &raw)
+	assert.NoError(t, err)
+	normalizeMemos(raw)
+	b, _ := json.Marshal(raw)
+	var tx solPTypes.ParsedTransaction
+	assert.NoError(t, json.Unmarshal(b, &tx))
+
+	rosTx := ToRosTx(tx)
+	assert.Equal(t, []string{"deposit 42"}, rosTx.Metadata[MemosKey])
+	assert.Equal(t, 2, len(rosTx.Operations))
+	for _, op := range rosTx.Operations {
+		assert.Equal(t, System__Transfer, op.Type)
+		assert.Equal(t, "deposit 42", op.Metadata[MemoKey])
+	}
+}